@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/backup"
+	"github.com/BrownieBrown/dolores/internal/config"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func runCommand(cfg *config.ApiConfig, name string, args []string) error {
+	switch name {
+	case "migrate":
+		return runMigrate(cfg, args)
+	case "backup":
+		return runBackup(cfg, args)
+	case "restore":
+		return runRestore(cfg, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+func runMigrate(cfg *config.ApiConfig, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "show pending migrations without writing them")
+	path := flags.String("path", cfg.DatabasePath, "database file to migrate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var from int
+	var applied []database.Migration
+	var err error
+	switch cfg.DatabaseDriver {
+	case "json":
+		from, applied, err = database.MigrateJSON(*path, *dryRun)
+	case "sqlite":
+		from, applied, err = database.MigrateSQLite(*path, *dryRun)
+	case "memory":
+		fmt.Println("memory store has nothing to migrate")
+		return nil
+	default:
+		return fmt.Errorf("unknown DB_DRIVER %q", cfg.DatabaseDriver)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Printf("%s is up to date at version %d\n", *path, from)
+		return nil
+	}
+
+	for _, m := range applied {
+		fmt.Printf("  %d: %s\n", m.Version, m.Description)
+	}
+
+	if *dryRun {
+		fmt.Printf("%s would be migrated from version %d to %d (dry run, nothing written)\n", *path, from, database.SchemaVersion())
+		return nil
+	}
+
+	fmt.Printf("%s migrated from version %d to %d\n", *path, from, database.SchemaVersion())
+
+	return nil
+}
+
+func runBackup(cfg *config.ApiConfig, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	list := flags.Bool("list", false, "list existing snapshots instead of creating one")
+	dir := flags.String("dir", cfg.BackupDir, "directory holding the snapshots")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	backups := backup.NewManager(db, *dir, cfg.BackupRetention)
+	if *list {
+		infos, err := backups.List()
+		if err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			fmt.Printf("%s\t%s\tschema %d\t%d bytes\n", info.Name, info.CreatedAt.Format(time.RFC3339), info.SchemaVersion, info.Size)
+		}
+		return nil
+	}
+
+	info, err := backups.Create()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created %s\n", filepath.Join(*dir, info.Name))
+
+	return nil
+}
+
+// runRestore restores a snapshot into a stopped server's store. A running
+// server should be restored through the admin API instead.
+func runRestore(cfg *config.ApiConfig, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	dir := flags.String("dir", cfg.BackupDir, "directory holding the snapshots")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: dolores restore [-dir dir] <snapshot name or path>")
+	}
+
+	path := flags.Arg(0)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(*dir, path)
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	info, err := backup.RestoreFile(db, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("restored %s taken at %s\n", path, info.CreatedAt.Format(time.RFC3339))
+
+	return nil
+}
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/BrownieBrown/dolores/internal/api/handler"
 	middleware2 "github.com/BrownieBrown/dolores/internal/api/middleware"
 	"github.com/BrownieBrown/dolores/internal/api/router"
 	"github.com/BrownieBrown/dolores/internal/api/server"
+	"github.com/BrownieBrown/dolores/internal/backup"
 	"github.com/BrownieBrown/dolores/internal/config"
 	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/media"
+	"github.com/BrownieBrown/dolores/internal/scheduler"
 	"github.com/joho/godotenv"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -17,30 +27,142 @@ func main() {
 		log.Fatal("Error loading .env file")
 	}
 
-	const dbPath = "./database.json"
-	const port = "8080"
-
 	cfg := config.LoadConfig()
 
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	r := router.NewRouter()
-	db := database.NewDB(dbPath)
-	err = db.DeleteOldDBFileIfExists(dbPath)
+	db, err := openStore(cfg)
 	if err != nil {
 		log.Fatal(err)
-
 	}
+	defer db.Close()
+
+	// Stop the scheduler before the deferred Close above runs.
+	ctx, cancel := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.New(db, scheduler.SystemClock, cfg.SchedulerInterval).Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-schedulerDone
+	}()
 
-	ch := handler.NewChirpHandler(cfg, db)
+	mediaStore := media.NewStore(cfg.MediaDir, int64(cfg.MediaMaxBytes))
+	ch := handler.NewChirpHandler(cfg, db, mediaStore)
 	hh := handler.NewHealthHandler(cfg)
 	uh := handler.NewUserHandler(cfg, db)
 	mh := handler.NewMetricsHandler(cfg)
-	r.Init(ch, hh, uh, mh)
+	bh := handler.NewBackupHandler(cfg, backup.NewManager(db, cfg.BackupDir, cfg.BackupRetention))
+	mdh := handler.NewMediaHandler(cfg, mediaStore)
+	r.Init(ch, hh, uh, mh, bh, mdh)
 
 	corsMux := middleware2.Cors(r)
 
-	srv := server.NewServer(port, corsMux)
+	srv := server.NewServer(cfg.Port, corsMux)
+
+	// Shut down cleanly on interrupt so buffered database changes are flushed.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-stop
+		if err := srv.Shutdown(context.Background()); err != nil {
+			log.Println(err)
+		}
+	}()
+
 	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-shutdownDone
+	} else if err != nil {
+		log.Println(err)
+	}
+}
+
+func openStore(cfg *config.ApiConfig) (database.Store, error) {
+	switch cfg.DatabaseMode {
+	case "persistent":
+	case "ephemeral", "seed":
+		if err := wipeStore(cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown DB_MODE %q", cfg.DatabaseMode)
+	}
+
+	db, err := openDatabase(cfg)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	if cfg.DatabaseMode == "seed" {
+		fixture, err := database.LoadFixture(cfg.DatabaseSeedPath)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("loading seed fixture: %w", err)
+		}
+
+		if err := db.Restore(fixture); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+// openDatabase opens the configured store as it is, whatever DB_MODE says.
+func openDatabase(cfg *config.ApiConfig) (database.Store, error) {
+	var db database.Store
+	var err error
+	switch cfg.DatabaseDriver {
+	case "json":
+		durability := database.Durability{FlushInterval: cfg.DatabaseFlush}
+		switch cfg.DatabaseDurability {
+		case "sync":
+			durability.SyncEveryWrite = true
+		case "batched":
+		default:
+			return nil, fmt.Errorf("unknown DB_DURABILITY %q", cfg.DatabaseDurability)
+		}
+		db, err = database.NewDB(cfg.DatabasePath, durability)
+	case "sqlite":
+		db, err = database.NewSQLiteDB(cfg.DatabasePath)
+	case "memory":
+		db = database.NewMemoryDB()
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q", cfg.DatabaseDriver)
+	}
+	if err != nil {
+		return nil, err
 	}
+
+	return db, nil
+}
+
+func wipeStore(cfg *config.ApiConfig) error {
+	var paths []string
+	switch cfg.DatabaseDriver {
+	case "json":
+		paths = []string{cfg.DatabasePath, cfg.DatabasePath + ".wal"}
+	case "sqlite":
+		paths = []string{cfg.DatabasePath, cfg.DatabasePath + "-wal", cfg.DatabasePath + "-shm"}
+	}
+
+	for _, path := range paths {
+		if err := database.DeleteOldDBFileIfExists(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"github.com/BrownieBrown/dolores/internal/backup"
+	"github.com/BrownieBrown/dolores/internal/config"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"os"
+)
+
+type BackupHandler struct {
+	Config  *config.ApiConfig
+	Backups *backup.Manager
+}
+
+func NewBackupHandler(cfg *config.ApiConfig, backups *backup.Manager) *BackupHandler {
+	return &BackupHandler{
+		Config:  cfg,
+		Backups: backups,
+	}
+}
+
+func (bh *BackupHandler) CreateBackup(w http.ResponseWriter, r *http.Request) {
+	if !bh.authorize(w, r) {
+		return
+	}
+
+	info, err := bh.Backups.Create()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+
+	utils.WriteData(w, http.StatusCreated, info)
+}
+
+func (bh *BackupHandler) ListBackups(w http.ResponseWriter, r *http.Request) {
+	if !bh.authorize(w, r) {
+		return
+	}
+
+	infos, err := bh.Backups.List()
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to list backups")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, infos)
+}
+
+func (bh *BackupHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	if !bh.authorize(w, r) {
+		return
+	}
+
+	name := r.PathValue("name")
+	if name == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing name parameter")
+		return
+	}
+
+	info, err := bh.Backups.Restore(name)
+	if os.IsNotExist(err) {
+		utils.WriteError(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, info)
+}
+
+func (bh *BackupHandler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	apiKey, err := utils.ExtractAPIKeyFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return false
+	}
+
+	if bh.Config.AdminAPIKey == "" || apiKey != bh.Config.AdminAPIKey {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid API key")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+func (ch *ChirpHandler) BookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setBookmark(w, r, true)
+}
+
+func (ch *ChirpHandler) UnbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setBookmark(w, r, false)
+}
+
+func (ch *ChirpHandler) setBookmark(w http.ResponseWriter, r *http.Request, bookmarked bool) {
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id := r.PathValue("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid id parameter")
+		return
+	}
+
+	var chirp models.Chirp
+	if bookmarked {
+		chirp, err = ch.Database.BookmarkChirp(id, userID)
+	} else {
+		chirp, err = ch.Database.UnbookmarkChirp(id, userID)
+	}
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	chirps := []models.Chirp{chirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps[0])
+}
+
+// GetBookmarks lists the chirps the signed-in user bookmarked, most recently
+// bookmarked first, one page at a time. Bookmarks are private, so there is no
+// way to list anyone else's.
+func (ch *ChirpHandler) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	ch.serveFeed(w, r, database.ChirpQuery{BookmarkedBy: userID})
+}
@@ -3,26 +3,46 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/BrownieBrown/dolores/internal/config"
 	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/media"
 	"github.com/BrownieBrown/dolores/internal/models"
 	"github.com/BrownieBrown/dolores/internal/utils"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	errChirpNotFound    = errors.New("chirp not found")
+	errNotChirpAuthor   = errors.New("not the chirp author")
+	errEditWindowClosed = errors.New("edit window closed")
+	errParentNotFound   = errors.New("parent chirp not found")
+	errOriginalNotFound = errors.New("original chirp not found")
+	errRechirpEdit      = errors.New("rechirps cannot be edited")
+	errTooManyMedia     = errors.New("too many media attachments")
+	errMediaNotFound    = errors.New("media not found")
+	errDraftNotFound    = errors.New("draft not found")
+	errRechirpPin       = errors.New("rechirps cannot be pinned")
+	errTooManyPins      = errors.New("too many pinned chirps")
 )
 
 type ChirpHandler struct {
 	Config   *config.ApiConfig
-	Database *database.DB
+	Database database.Store
+	Media    *media.Store
 }
 
-func NewChirpHandler(config *config.ApiConfig, database *database.DB) *ChirpHandler {
+func NewChirpHandler(config *config.ApiConfig, database database.Store, media *media.Store) *ChirpHandler {
 	return &ChirpHandler{
 		Config:   config,
 		Database: database,
+		Media:    media,
 	}
 }
 
@@ -51,17 +71,36 @@ func (ch *ChirpHandler) CreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var chirp models.Chirp
-	if err := json.NewDecoder(r.Body).Decode(&chirp); err != nil {
+	var req models.CreateChirpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
 
+	chirp := models.Chirp{Body: req.Body, InReplyTo: req.InReplyTo, OriginalID: req.OriginalID}
 	if err := validateChirp(chirp); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err.Error())
 		return // Make sure to return after writing the error
 	}
 
+	if err := validatePublishAt(req.PublishAt); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirp.Media, err = ch.resolveMedia(req.MediaIDs)
+	switch {
+	case errors.Is(err, errTooManyMedia):
+		utils.WriteError(w, http.StatusBadRequest, fmt.Sprintf("A chirp can have at most %d media attachments", ch.Config.MediaMaxPerChirp))
+		return
+	case errors.Is(err, errMediaNotFound):
+		utils.WriteError(w, http.StatusBadRequest, "Unknown media id")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
 	result, err := cleanUpMessage(chirp.Body)
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
@@ -70,38 +109,127 @@ func (ch *ChirpHandler) CreateChirp(w http.ResponseWriter, r *http.Request) {
 
 	chirp.Body = result
 	chirp.AuthorID = userID
+	chirp.Kind = models.ChirpKindChirp
+	if chirp.OriginalID != 0 {
+		chirp.Kind = models.ChirpKindQuote
+	}
 
-	newChirp, err := ch.Database.CreateChirp(chirp)
+	var newChirp models.Chirp
+	var newDraft models.Draft
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		if chirp.InReplyTo != 0 {
+			parent, err := sharedChirp(tx, chirp.InReplyTo)
+			if err != nil {
+				return errParentNotFound
+			}
+			chirp.InReplyTo = parent.ID
+		}
 
-	if err != nil {
+		if chirp.OriginalID != 0 {
+			original, err := sharedChirp(tx, chirp.OriginalID)
+			if err != nil {
+				return errOriginalNotFound
+			}
+			chirp.OriginalID = original.ID
+		}
+
+		var err error
+		if req.Draft || req.PublishAt != nil {
+			newDraft, err = tx.CreateDraft(models.Draft{Body: chirp.Body, AuthorID: chirp.AuthorID, InReplyTo: chirp.InReplyTo, OriginalID: chirp.OriginalID, Media: chirp.Media, PublishAt: req.PublishAt})
+			return err
+		}
+
+		newChirp, err = tx.CreateChirp(chirp)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errParentNotFound):
+		utils.WriteError(w, http.StatusBadRequest, "The chirp being replied to does not exist")
+		return
+	case errors.Is(err, errOriginalNotFound):
+		utils.WriteError(w, http.StatusBadRequest, "The quoted chirp does not exist")
+		return
+	case err != nil:
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to create chirp")
 		return
 	}
 
+	if newDraft.ID != 0 {
+		utils.WriteData(w, http.StatusCreated, newDraft)
+		return
+	}
+
+	chirps := []models.Chirp{newChirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	newChirp = chirps[0]
+
 	utils.WriteData(w, http.StatusCreated, newChirp)
 }
 
 func (ch *ChirpHandler) GetChirps(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
 
 	queryParams := r.URL.Query()
-	defaultSortOrder := "asc"
-
-	if len(queryParams) > 0 {
-		ch.handleQueryParams(queryParams, w)
+	query, err := parseChirpQuery(queryParams)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	chirps, err := ch.Database.GetChirps(defaultSortOrder)
+	paginated := queryParams.Has("limit") || queryParams.Has("cursor")
+	if paginated && query.Limit == 0 {
+		query.Limit = database.DefaultPageLimit
+	}
+
+	// An author's pinned chirps head their feed instead of taking their
+	// place in it.
+	pinsFirst := authorFeed(query)
+	query.Unpinned = pinsFirst
 
+	page, err := ch.Database.ListChirps(query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, "invalid cursor parameter")
+		return
+	}
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
 		return
 	}
 
-	utils.WriteData(w, http.StatusOK, chirps)
+	chirps := page.Chirps
+	// Only the first page has no previous one, however it was reached.
+	if pinsFirst && (query.Cursor == "" || (page.PrevCursor == "" && len(chirps) > 0)) {
+		pins, err := ch.Database.GetPinnedChirps(query.AuthorIDs[0])
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		chirps = append(pins, chirps...)
+	}
+	if chirps == nil {
+		chirps = []models.Chirp{}
+	}
+
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	// Plain listings keep returning a bare array so existing clients are
+	// unaffected.
+	if !paginated {
+		utils.WriteData(w, http.StatusOK, chirps)
+		return
+	}
+
+	writeChirpPage(w, r, chirps, page)
 }
 
 func (ch *ChirpHandler) GetChirp(w http.ResponseWriter, r *http.Request) {
@@ -122,7 +250,13 @@ func (ch *ChirpHandler) GetChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.WriteData(w, http.StatusOK, chirp)
+	chirps := []models.Chirp{chirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps[0])
 }
 
 func (ch *ChirpHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
@@ -151,7 +285,7 @@ func (ch *ChirpHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	chirp, err := ch.Database.GetChirp(id)
-	if err != nil {
+	if err != nil || chirp.Deleted {
 		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
 		return
 	}
@@ -176,6 +310,345 @@ func (ch *ChirpHandler) DeleteChirp(w http.ResponseWriter, r *http.Request) {
 	utils.WriteData(w, http.StatusOK, nil)
 }
 
+func (ch *ChirpHandler) EditChirp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing id parameter")
+		return
+	}
+
+	var edit models.Chirp
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateChirp(edit); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := cleanUpMessage(edit.Body)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	var updated models.Chirp
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		chirp, err := tx.GetChirp(id)
+		if err != nil || chirp.Deleted {
+			return errChirpNotFound
+		}
+
+		if chirp.AuthorID != userID {
+			return errNotChirpAuthor
+		}
+
+		if chirp.Kind == models.ChirpKindRechirp {
+			return errRechirpEdit
+		}
+
+		user, err := tx.GetUserByID(userID)
+		if err != nil {
+			return err
+		}
+
+		window := ch.Config.ChirpEditWindow
+		if user.PremiumMember {
+			window = ch.Config.ChirpEditWindowRed
+		}
+		if time.Since(chirp.CreatedAt) > window {
+			return errEditWindowClosed
+		}
+
+		chirp.Body = result
+		updated, err = tx.UpdateChirp(chirp)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case errors.Is(err, errNotChirpAuthor):
+		utils.WriteError(w, http.StatusForbidden, "You are not allowed to edit this chirp")
+		return
+	case errors.Is(err, errEditWindowClosed):
+		utils.WriteError(w, http.StatusForbidden, "The edit window for this chirp has closed")
+		return
+	case errors.Is(err, errRechirpEdit):
+		utils.WriteError(w, http.StatusBadRequest, "Rechirps cannot be edited")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update chirp")
+		return
+	}
+
+	chirps := []models.Chirp{updated}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	updated = chirps[0]
+
+	utils.WriteData(w, http.StatusOK, updated)
+}
+
+func (ch *ChirpHandler) GetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing id parameter")
+		return
+	}
+
+	revisions, err := ch.Database.GetChirpRevisions(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, revisions)
+}
+
+func (ch *ChirpHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing id parameter")
+		return
+	}
+
+	replies, err := ch.Database.GetReplies(id)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	if err := ch.prepareChirps(r, replies); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, replies)
+}
+
+// GetThread returns the conversation a chirp belongs to as a tree rooted at
+// its first chirp. depth limits how many levels of replies are included.
+func (ch *ChirpHandler) GetThread(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing id parameter")
+		return
+	}
+
+	depth := database.DefaultThreadDepth
+	if value := r.URL.Query().Get("depth"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > database.MaxThreadDepth {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Sprintf("invalid depth parameter, expected 0 to %d", database.MaxThreadDepth))
+			return
+		}
+		depth = n
+	}
+
+	thread, err := ch.Database.GetThread(id, depth)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	}
+
+	if err := ch.prepareThread(r, &thread); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, thread)
+}
+
+// sharedChirp resolves the chirp a reply, quote or like points at. Rechirps
+// stand for their original, and deleted chirps cannot be referenced.
+func sharedChirp(tx database.Tx, id int) (models.Chirp, error) {
+	chirp, err := tx.GetChirp(strconv.Itoa(id))
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Kind == models.ChirpKindRechirp {
+		chirp, err = tx.GetChirp(strconv.Itoa(chirp.OriginalID))
+		if err != nil {
+			return models.Chirp{}, err
+		}
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, errChirpNotFound
+	}
+
+	return chirp, nil
+}
+
+// prepareChirps fills in the fields of chirps that depend on the request
+// rather than on what is stored.
+func (ch *ChirpHandler) prepareChirps(r *http.Request, chirps []models.Chirp) error {
+	ptrs := make([]*models.Chirp, len(chirps))
+	for i := range chirps {
+		ptrs[i] = &chirps[i]
+	}
+
+	return ch.decorate(r, ptrs)
+}
+
+// prepareThread is prepareChirps for every chirp in a thread.
+func (ch *ChirpHandler) prepareThread(r *http.Request, thread *models.ChirpThread) error {
+	var chirps []*models.Chirp
+	var collect func(t *models.ChirpThread)
+	collect = func(t *models.ChirpThread) {
+		chirps = append(chirps, &t.Chirp)
+		for i := range t.Replies {
+			collect(&t.Replies[i])
+		}
+	}
+	collect(thread)
+
+	return ch.decorate(r, chirps)
+}
+
+// decorate embeds the original of every rechirp and quote, fills in the
+// author handles, then marks the chirps, originals included, the caller
+// likes.
+func (ch *ChirpHandler) decorate(r *http.Request, chirps []*models.Chirp) error {
+	all := chirps
+	var originalIDs []int
+	for _, chirp := range chirps {
+		if chirp.OriginalID != 0 {
+			originalIDs = append(originalIDs, chirp.OriginalID)
+		}
+	}
+
+	if len(originalIDs) > 0 {
+		originals, err := ch.Database.GetChirpsByID(originalIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, chirp := range chirps {
+			if chirp.OriginalID == 0 {
+				continue
+			}
+			original, ok := originals[chirp.OriginalID]
+			if !ok {
+				original = models.Chirp{ID: chirp.OriginalID, Deleted: true}
+			}
+			chirp.Original = &original
+			all = append(all, chirp.Original)
+		}
+	}
+
+	var authorIDs []int
+	seen := make(map[int]bool)
+	for _, chirp := range all {
+		if chirp.AuthorID != 0 && !seen[chirp.AuthorID] {
+			seen[chirp.AuthorID] = true
+			authorIDs = append(authorIDs, chirp.AuthorID)
+		}
+	}
+
+	if len(authorIDs) > 0 {
+		authors, err := ch.Database.GetUsersByID(authorIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, chirp := range all {
+			chirp.AuthorHandle = authors[chirp.AuthorID].Handle
+		}
+	}
+
+	userID := ch.viewerID(r)
+	if userID == 0 || len(all) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(all))
+	for i, chirp := range all {
+		ids[i] = chirp.ID
+	}
+
+	liked, err := ch.Database.LikedChirpIDs(userID, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, chirp := range all {
+		chirp.LikedByMe = liked[chirp.ID]
+	}
+
+	return nil
+}
+
+// resolveMedia looks up the attachments a new chirp refers to. Listing the
+// same upload twice attaches it once.
+func (ch *ChirpHandler) resolveMedia(ids []string) ([]models.Attachment, error) {
+	var attachments []models.Attachment
+	for _, id := range ids {
+		if slices.ContainsFunc(attachments, func(a models.Attachment) bool { return a.ID == id }) {
+			continue
+		}
+		if len(attachments) == ch.Config.MediaMaxPerChirp {
+			return nil, errTooManyMedia
+		}
+
+		attachment, err := ch.Media.Get(id)
+		if errors.Is(err, media.ErrNotFound) {
+			return nil, errMediaNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, nil
+}
+
 func validateChirp(chirp models.Chirp) error {
 	maxLength := 140
 	minLength := 1
@@ -215,41 +688,125 @@ func cleanUpMessage(input string) (string, error) {
 	return result, nil
 }
 
-func (ch *ChirpHandler) handleQueryParams(queryParams url.Values, w http.ResponseWriter) {
-	authorID := queryParams.Get("author_id")
-	sortOrder := queryParams.Get("sort")
+// chirpQueryParams are the parameters GET /api/chirps understands; author_id
+// is the only one that may be repeated.
+var chirpQueryParams = map[string]bool{
+	"author_id": true,
+	"sort":      true,
+	"since":     true,
+	"until":     true,
+	"contains":  true,
+	"has_media": true,
+	"limit":     true,
+	"cursor":    true,
+}
 
-	if authorID != "" {
+// parseChirpQuery turns the listing parameters into a store query. Every
+// filter given is applied together with the others.
+func parseChirpQuery(queryParams url.Values) (database.ChirpQuery, error) {
+	var query database.ChirpQuery
+	for name, values := range queryParams {
+		if !chirpQueryParams[name] {
+			return query, fmt.Errorf("unknown query parameter %q", name)
+		}
+		if name != "author_id" && len(values) > 1 {
+			return query, fmt.Errorf("%s parameter given more than once", name)
+		}
+	}
 
-		chirps, err := ch.Database.GetChirpsByAuthorID(authorID)
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
-			return
+	for _, value := range queryParams["author_id"] {
+		for _, field := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || id < 1 {
+				return query, errors.New("invalid author_id parameter, expected user IDs")
+			}
+			if !slices.Contains(query.AuthorIDs, id) {
+				query.AuthorIDs = append(query.AuthorIDs, id)
+			}
 		}
+	}
 
-		utils.WriteData(w, http.StatusOK, chirps)
-		return
+	query.SortOrder = queryParams.Get("sort")
+	if query.SortOrder == "" {
+		query.SortOrder = "asc"
+	}
+	if query.SortOrder != "asc" && query.SortOrder != "desc" {
+		return query, errors.New("invalid sort parameter, expected asc or desc")
 	}
 
-	if sortOrder == "desc" {
-		chirps, err := ch.Database.GetChirps(sortOrder)
+	var err error
+	if query.Since, err = parseTimeParam(queryParams, "since"); err != nil {
+		return query, err
+	}
+	if query.Until, err = parseTimeParam(queryParams, "until"); err != nil {
+		return query, err
+	}
+
+	query.Contains = queryParams.Get("contains")
+
+	if value := queryParams.Get("has_media"); value != "" {
+		hasMedia, err := strconv.ParseBool(value)
 		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
-			return
+			return query, errors.New("invalid has_media parameter, expected true or false")
 		}
-
-		utils.WriteData(w, http.StatusOK, chirps)
-		return
+		query.HasMedia = &hasMedia
 	}
 
-	if sortOrder == "asc" {
-		chirps, err := ch.Database.GetChirps(sortOrder)
-		if err != nil {
-			utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
-			return
+	if value := queryParams.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return query, errors.New("invalid limit parameter, expected a positive integer")
 		}
+		query.Limit = limit
+	}
 
-		utils.WriteData(w, http.StatusOK, chirps)
-		return
+	query.Cursor = queryParams.Get("cursor")
+
+	return query, nil
+}
+
+// writeChirpPage writes chirps as one page of a listing, linking to the
+// pages around it.
+func writeChirpPage(w http.ResponseWriter, r *http.Request, chirps []models.Chirp, page database.ChirpPage) {
+	var links []string
+	if page.NextCursor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"next\"", pageURL(r, page.NextCursor)))
+	}
+	if page.PrevCursor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"prev\"", pageURL(r, page.PrevCursor)))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	utils.WriteData(w, http.StatusOK, models.ChirpPageResponse{
+		Chirps:     chirps,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	})
+}
+
+// pageURL is the request URL with its cursor replaced.
+func pageURL(r *http.Request, cursor string) string {
+	u := url.URL{Path: r.URL.Path}
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	u.RawQuery = params.Encode()
+
+	return u.String()
+}
+
+// parseTimeParam reads an optional RFC 3339 timestamp from the query.
+func parseTimeParam(queryParams url.Values, name string) (time.Time, error) {
+	value := queryParams.Get(name)
+	if value == "" {
+		return time.Time{}, nil
 	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s parameter, expected an RFC 3339 timestamp", name)
+	}
+
+	return t, nil
 }
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// GetDrafts lists the authenticated user's drafts, scheduled or not. Drafts
+// are private, so there is no way to list anyone else's.
+func (ch *ChirpHandler) GetDrafts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	drafts, err := ch.Database.GetDrafts(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, drafts)
+}
+
+// UpdateDraft replaces a draft's body and schedule. Scheduling it for a new
+// time, or unscheduling it with a null publish_at, is how a scheduled chirp
+// is moved or held back.
+func (ch *ChirpHandler) UpdateDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid draft id")
+		return
+	}
+
+	var req models.UpdateDraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateChirp(models.Chirp{Body: req.Body}); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validatePublishAt(req.PublishAt); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := cleanUpMessage(req.Body)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	var updated models.Draft
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		draft, err := ownDraft(tx, id, userID)
+		if err != nil {
+			return err
+		}
+
+		draft.Body = result
+		draft.PublishAt = req.PublishAt
+		updated, err = tx.UpdateDraft(draft)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errDraftNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Draft not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update draft")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, updated)
+}
+
+// DeleteDraft discards a draft, which also cancels it if it is scheduled.
+func (ch *ChirpHandler) DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid draft id")
+		return
+	}
+
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		if _, err := ownDraft(tx, id, userID); err != nil {
+			return err
+		}
+
+		return tx.DeleteDraft(id)
+	})
+
+	switch {
+	case errors.Is(err, errDraftNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Draft not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to delete draft")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, nil)
+}
+
+// PublishDraft posts a draft right away, whether or not it is scheduled.
+func (ch *ChirpHandler) PublishDraft(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid draft id")
+		return
+	}
+
+	var chirp models.Chirp
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		if _, err := ownDraft(tx, id, userID); err != nil {
+			return err
+		}
+
+		var err error
+		chirp, err = database.PublishDraft(tx, id)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errDraftNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Draft not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to publish draft")
+		return
+	}
+
+	chirps := []models.Chirp{chirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusCreated, chirps[0])
+}
+
+// ownDraft looks up one of userID's drafts. Other users' drafts are reported
+// as missing, so their existence does not leak.
+func ownDraft(tx database.Tx, id, userID int) (models.Draft, error) {
+	draft, err := tx.GetDraft(id)
+	if errors.Is(err, database.ErrDraftNotFound) {
+		return models.Draft{}, errDraftNotFound
+	}
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	if draft.AuthorID != userID {
+		return models.Draft{}, errDraftNotFound
+	}
+
+	return draft, nil
+}
+
+func validatePublishAt(publishAt *time.Time) error {
+	if publishAt != nil && !publishAt.After(time.Now()) {
+		return errors.New("publish_at must be in the future")
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+func (uh *UserHandler) Follow(w http.ResponseWriter, r *http.Request) {
+	uh.setFollow(w, r, true)
+}
+
+func (uh *UserHandler) Unfollow(w http.ResponseWriter, r *http.Request) {
+	uh.setFollow(w, r, false)
+}
+
+func (uh *UserHandler) setFollow(w http.ResponseWriter, r *http.Request, following bool) {
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, uh.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	followerID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	followeeID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	if followeeID == followerID {
+		utils.WriteError(w, http.StatusBadRequest, "You cannot follow yourself")
+		return
+	}
+
+	err = uh.Database.Tx(func(tx database.Tx) error {
+		if !following {
+			return tx.Unfollow(followerID, followeeID)
+		}
+
+		if _, err := tx.GetUserByID(followeeID); err != nil {
+			return errUserNotFound
+		}
+
+		return tx.Follow(followerID, followeeID)
+	})
+
+	switch {
+	case errors.Is(err, errUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, nil)
+}
+
+func (uh *UserHandler) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	uh.listFollows(w, r, uh.Database.GetFollowers)
+}
+
+func (uh *UserHandler) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	uh.listFollows(w, r, uh.Database.GetFollowing)
+}
+
+func (uh *UserHandler) listFollows(w http.ResponseWriter, r *http.Request, list func(userID int) ([]models.Follow, error)) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	if _, err := uh.Database.GetUserByID(userID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	follows, err := list(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, follows)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+func (ch *ChirpHandler) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setLike(w, r, true)
+}
+
+func (ch *ChirpHandler) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setLike(w, r, false)
+}
+
+func (ch *ChirpHandler) setLike(w http.ResponseWriter, r *http.Request, liked bool) {
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid id parameter")
+		return
+	}
+
+	var chirp models.Chirp
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		original, err := sharedChirp(tx, id)
+		if err != nil {
+			return errChirpNotFound
+		}
+
+		if liked {
+			chirp, err = tx.LikeChirp(strconv.Itoa(original.ID), userID)
+		} else {
+			chirp, err = tx.UnlikeChirp(strconv.Itoa(original.ID), userID)
+		}
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	chirps := []models.Chirp{chirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps[0])
+}
+
+func (ch *ChirpHandler) GetUserLikes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	if _, err := ch.Database.GetUserByID(userID); err != nil {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	chirps, err := ch.Database.GetLikedChirps(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps)
+}
+
+// viewerID returns the user making the request, or 0 for anonymous requests.
+// Public endpoints treat an invalid token like no token at all.
+func (ch *ChirpHandler) viewerID(r *http.Request) int {
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		return 0
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		return 0
+	}
+
+	userID, _ := strconv.Atoi(claims.Subject)
+
+	return userID
+}
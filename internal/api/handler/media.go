@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/config"
+	"github.com/BrownieBrown/dolores/internal/media"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"io"
+	"net/http"
+)
+
+// multipartOverhead is how much a request may exceed the upload limit by, for
+// the multipart headers and boundaries around the file.
+const multipartOverhead = 64 << 10
+
+type MediaHandler struct {
+	Config *config.ApiConfig
+	Media  *media.Store
+}
+
+func NewMediaHandler(cfg *config.ApiConfig, store *media.Store) *MediaHandler {
+	return &MediaHandler{
+		Config: cfg,
+		Media:  store,
+	}
+}
+
+// UploadMedia stores the image sent as the "file" field of a multipart form.
+// The returned ID can then be attached to a chirp.
+func (mh *MediaHandler) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if _, err := utils.ValidateAccessToken(tokenString, mh.Config); err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(mh.Config.MediaMaxBytes)+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Expected a multipart/form-data request")
+		return
+	}
+
+	var file io.Reader
+	for file == nil {
+		part, err := reader.NextPart()
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		if part.FormName() == "file" {
+			file = part
+		}
+	}
+
+	attachment, err := mh.Media.Save(file)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	utils.WriteData(w, http.StatusCreated, attachment)
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, io.EOF):
+		utils.WriteError(w, http.StatusBadRequest, "Missing file field")
+	case errors.Is(err, media.ErrTooLarge), errors.As(err, &maxBytesErr):
+		utils.WriteError(w, http.StatusRequestEntityTooLarge, "File too large")
+	case errors.Is(err, media.ErrUnsupportedType):
+		utils.WriteError(w, http.StatusUnsupportedMediaType, "Unsupported file type, expected a JPEG or PNG image")
+	case errors.Is(err, media.ErrInvalidImage):
+		utils.WriteError(w, http.StatusBadRequest, "Invalid image")
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to store file")
+	}
+}
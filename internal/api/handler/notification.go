@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (uh *UserHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, uh.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	query, err := parseNotificationQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notifications, err := uh.Database.GetNotifications(userID, query)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	unreadCount, err := uh.Database.CountUnreadNotifications(userID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, models.NotificationsResponse{Notifications: notifications, UnreadCount: unreadCount})
+}
+
+func (uh *UserHandler) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, uh.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	if err := uh.Database.MarkNotificationsRead(userID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, nil)
+}
+
+// parseNotificationQuery reads the unread, before and limit parameters.
+func parseNotificationQuery(queryParams url.Values) (database.NotificationQuery, error) {
+	query := database.NotificationQuery{Limit: database.DefaultPageLimit}
+	for name, values := range queryParams {
+		if name != "unread" && name != "before" && name != "limit" {
+			return query, fmt.Errorf("unknown query parameter %q", name)
+		}
+		if len(values) > 1 {
+			return query, fmt.Errorf("%s parameter given more than once", name)
+		}
+	}
+
+	if value := queryParams.Get("unread"); value != "" {
+		unread, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, errors.New("invalid unread parameter, expected true or false")
+		}
+		query.UnreadOnly = unread
+	}
+
+	if value := queryParams.Get("before"); value != "" {
+		before, err := strconv.Atoi(value)
+		if err != nil || before < 1 {
+			return query, errors.New("invalid before parameter, expected a notification ID")
+		}
+		query.Before = before
+	}
+
+	if value := queryParams.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return query, errors.New("invalid limit parameter, expected a positive integer")
+		}
+		query.Limit = limit
+	}
+
+	return query, nil
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+const maxPinnedChirps = 3
+
+func (ch *ChirpHandler) PinChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setPinned(w, r, true)
+}
+
+func (ch *ChirpHandler) UnpinChirp(w http.ResponseWriter, r *http.Request) {
+	ch.setPinned(w, r, false)
+}
+
+// setPinned pins one of the signed-in user's chirps to the top of their
+// feed, or unpins it. Pinning an already pinned chirp keeps its place.
+func (ch *ChirpHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing id parameter")
+		return
+	}
+
+	var chirp models.Chirp
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		current, err := tx.GetChirp(id)
+		if err != nil || current.Deleted {
+			return errChirpNotFound
+		}
+
+		if current.AuthorID != userID {
+			return errNotChirpAuthor
+		}
+
+		if !pinned {
+			chirp, err = tx.UnpinChirp(id)
+			return err
+		}
+
+		if current.Kind == models.ChirpKindRechirp {
+			return errRechirpPin
+		}
+
+		if current.PinnedAt == nil {
+			pins, err := tx.GetPinnedChirps(userID)
+			if err != nil {
+				return err
+			}
+			if len(pins) >= maxPinnedChirps {
+				return errTooManyPins
+			}
+		}
+
+		chirp, err = tx.PinChirp(id)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case errors.Is(err, errNotChirpAuthor):
+		utils.WriteError(w, http.StatusForbidden, "You can only pin your own chirps")
+		return
+	case errors.Is(err, errRechirpPin):
+		utils.WriteError(w, http.StatusBadRequest, "Rechirps cannot be pinned")
+		return
+	case errors.Is(err, errTooManyPins):
+		utils.WriteError(w, http.StatusConflict, fmt.Sprintf("You can pin at most %d chirps", maxPinnedChirps))
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	chirps := []models.Chirp{chirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps[0])
+}
+
+// authorFeed reports whether query is a plain listing of one author's
+// chirps, which lists the chirps they pinned first.
+func authorFeed(query database.ChirpQuery) bool {
+	return len(query.AuthorIDs) == 1 && query.Since.IsZero() && query.Until.IsZero() && query.Contains == "" && query.HasMedia == nil && query.Tag == ""
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+func (uh *UserHandler) GetProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	handle := r.PathValue("handle")
+	if !database.ValidHandle(handle) {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	user, err := uh.Database.GetUserByHandle(handle)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	followers, err := uh.Database.CountFollowers(user.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	following, err := uh.Database.CountFollowing(user.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	profile := models.UserProfile{
+		ID:             user.ID,
+		Handle:         user.Handle,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarURL:      user.AvatarURL,
+		PremiumMember:  user.PremiumMember,
+		FollowerCount:  followers,
+		FollowingCount: following,
+	}
+
+	utils.WriteData(w, http.StatusOK, profile)
+}
+
+func validateHandle(handle string) error {
+	if handle != "" && !database.ValidHandle(handle) {
+		return errors.New("Invalid handle, expected 1 to 15 letters, digits or underscores")
+	}
+
+	return nil
+}
+
+func validateProfile(displayName, bio, avatarURL string) error {
+	if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+		return errors.New("Display name is too long")
+	}
+
+	if utf8.RuneCountInString(bio) > maxBioLength {
+		return errors.New("Bio is too long")
+	}
+
+	if avatarURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(avatarURL)
+	if err != nil || len(avatarURL) > maxAvatarURLLength || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Invalid avatar_url, expected an http or https URL")
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// Rechirp reposts a chirp for the authenticated user. Rechirping the same
+// chirp again returns the existing rechirp.
+func (ch *ChirpHandler) Rechirp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid id parameter")
+		return
+	}
+
+	var rechirp models.Chirp
+	status := http.StatusOK
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		original, err := sharedChirp(tx, id)
+		if err != nil {
+			return errChirpNotFound
+		}
+
+		rechirp, err = tx.GetRechirp(strconv.Itoa(original.ID), userID)
+		if err == nil {
+			return nil
+		}
+
+		rechirp, err = tx.CreateChirp(models.Chirp{Kind: models.ChirpKindRechirp, AuthorID: userID, OriginalID: original.ID})
+		status = http.StatusCreated
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to rechirp")
+		return
+	}
+
+	chirps := []models.Chirp{rechirp}
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, status, chirps[0])
+}
+
+// UndoRechirp removes the authenticated user's rechirp of a chirp, if any.
+func (ch *ChirpHandler) UndoRechirp(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid id parameter")
+		return
+	}
+
+	err = ch.Database.Tx(func(tx database.Tx) error {
+		original, err := sharedChirp(tx, id)
+		if err != nil {
+			return errChirpNotFound
+		}
+
+		rechirp, err := tx.GetRechirp(strconv.Itoa(original.ID), userID)
+		if err != nil {
+			return nil
+		}
+
+		return tx.DeleteChirp(strconv.Itoa(rechirp.ID))
+	})
+
+	switch {
+	case errors.Is(err, errChirpNotFound):
+		utils.WriteError(w, http.StatusNotFound, "Chirp not found")
+		return
+	case err != nil:
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to undo rechirp")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, nil)
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// SearchChirps finds the chirps matching q, best matches first. Words match
+// any word they start, "quoted phrases" match as written, and from:handle
+// and #tag narrow the results down.
+func (ch *ChirpHandler) SearchChirps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := ch.Database.SearchChirps(query)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, chirps)
+}
+
+// parseSearchQuery reads the q and optional limit parameters, the only ones
+// search takes.
+func parseSearchQuery(r *http.Request) (database.SearchQuery, error) {
+	queryParams := r.URL.Query()
+	for name, values := range queryParams {
+		if name != "q" && name != "limit" {
+			return database.SearchQuery{}, fmt.Errorf("unknown query parameter %q", name)
+		}
+		if len(values) > 1 {
+			return database.SearchQuery{}, fmt.Errorf("%s parameter given more than once", name)
+		}
+	}
+
+	query := database.ParseSearchQuery(queryParams.Get("q"))
+	if query.Empty() {
+		return database.SearchQuery{}, errors.New("missing q parameter")
+	}
+
+	query.Limit = database.DefaultPageLimit
+	if value := queryParams.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return database.SearchQuery{}, errors.New("invalid limit parameter, expected a positive integer")
+		}
+		query.Limit = min(limit, database.MaxPageLimit)
+	}
+
+	return query, nil
+}
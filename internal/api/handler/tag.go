@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// GetTagChirps lists the chirps using a hashtag, newest first, one page at a
+// time.
+func (ch *ChirpHandler) GetTagChirps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tag := database.FoldTag(r.PathValue("tag"))
+	if tag == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Missing tag parameter")
+		return
+	}
+
+	ch.serveFeed(w, r, database.ChirpQuery{Tag: tag})
+}
+
+func (ch *ChirpHandler) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	limit, err := parseTrendingLimit(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tags, err := ch.Database.TrendingTags(ch.Config.TagTrendingWindow, ch.Config.TagTrendingHalfLife, limit)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, tags)
+}
+
+// parseTrendingLimit reads the optional limit parameter, the only one
+// trending tags take.
+func parseTrendingLimit(r *http.Request) (int, error) {
+	queryParams := r.URL.Query()
+	for name, values := range queryParams {
+		if name != "limit" {
+			return 0, fmt.Errorf("unknown query parameter %q", name)
+		}
+		if len(values) > 1 {
+			return 0, errors.New("limit parameter given more than once")
+		}
+	}
+
+	value := queryParams.Get("limit")
+	if value == "" {
+		return database.DefaultTrendingLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errors.New("invalid limit parameter, expected a positive integer")
+	}
+
+	return min(limit, database.MaxTrendingLimit), nil
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"github.com/BrownieBrown/dolores/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+// GetTimeline lists the chirps of the signed-in user and everyone they
+// follow, newest first, one page at a time.
+func (ch *ChirpHandler) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, ch.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	ch.serveFeed(w, r, database.ChirpQuery{TimelineOf: userID})
+}
+
+// serveFeed writes one page of the chirps matching query, newest first. Feeds
+// only take the limit and cursor parameters.
+func (ch *ChirpHandler) serveFeed(w http.ResponseWriter, r *http.Request, query database.ChirpQuery) {
+	queryParams := r.URL.Query()
+	for name := range queryParams {
+		if name != "limit" && name != "cursor" {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Sprintf("unknown query parameter %q", name))
+			return
+		}
+	}
+
+	params, err := parseChirpQuery(queryParams)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.SortOrder = "desc"
+	query.Limit = params.Limit
+	if query.Limit == 0 {
+		query.Limit = database.DefaultPageLimit
+	}
+	query.Cursor = params.Cursor
+
+	page, err := ch.Database.ListChirps(query)
+	if errors.Is(err, database.ErrInvalidCursor) {
+		utils.WriteError(w, http.StatusBadRequest, "invalid cursor parameter")
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	chirps := page.Chirps
+	if chirps == nil {
+		chirps = []models.Chirp{}
+	}
+
+	if err := ch.prepareChirps(r, chirps); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	writeChirpPage(w, r, chirps, page)
+}
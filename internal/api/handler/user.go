@@ -2,6 +2,7 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/BrownieBrown/dolores/internal/config"
 	"github.com/BrownieBrown/dolores/internal/database"
 	"github.com/BrownieBrown/dolores/internal/models"
@@ -11,12 +12,18 @@ import (
 	"strconv"
 )
 
+var (
+	errUserNotFound = errors.New("user not found")
+	errEmailTaken   = errors.New("email already exists")
+	errHandleTaken  = errors.New("handle already exists")
+)
+
 type UserHandler struct {
 	Config   *config.ApiConfig
-	Database *database.DB
+	Database database.Store
 }
 
-func NewUserHandler(cfg *config.ApiConfig, database *database.DB) *UserHandler {
+func NewUserHandler(cfg *config.ApiConfig, database database.Store) *UserHandler {
 	return &UserHandler{
 		Config:   cfg,
 		Database: database,
@@ -35,13 +42,57 @@ func (uh *UserHandler) SignUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newUser, err := uh.Database.CreateUser(loginReq)
-	if err != nil {
+	if loginReq.Handle == "" {
+		utils.WriteError(w, http.StatusBadRequest, "Handle required")
+		return
+	}
+
+	if err := validateHandle(loginReq.Handle); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateProfile(loginReq.DisplayName, loginReq.Bio, loginReq.AvatarURL); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var newUser models.User
+	err := uh.Database.Tx(func(tx database.Tx) error {
+		if _, err := tx.GetUserByEmail(loginReq.Email); err == nil {
+			return errEmailTaken
+		}
+
+		if _, err := tx.GetUserByHandle(loginReq.Handle); err == nil {
+			return errHandleTaken
+		}
+
+		var err error
+		newUser, err = tx.CreateUser(loginReq)
+		return err
+	})
+
+	switch {
+	case errors.Is(err, errEmailTaken):
+		utils.WriteError(w, http.StatusConflict, "Email already exists")
+		return
+	case errors.Is(err, errHandleTaken):
+		utils.WriteError(w, http.StatusConflict, "Handle already exists")
+		return
+	case err != nil:
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
-	utils.WriteData(w, http.StatusCreated, models.SignUpResponse{ID: newUser.ID, Email: newUser.Email, PremiumMember: newUser.PremiumMember})
+	utils.WriteData(w, http.StatusCreated, models.SignUpResponse{
+		ID:            newUser.ID,
+		Email:         newUser.Email,
+		Handle:        newUser.Handle,
+		DisplayName:   newUser.DisplayName,
+		Bio:           newUser.Bio,
+		AvatarURL:     newUser.AvatarURL,
+		PremiumMember: newUser.PremiumMember,
+	})
 }
 
 func (uh *UserHandler) SignIn(w http.ResponseWriter, r *http.Request) {
@@ -81,6 +132,7 @@ func (uh *UserHandler) SignIn(w http.ResponseWriter, r *http.Request) {
 
 	signInResponse := models.SignInResponse{
 		Email:         user.Email,
+		Handle:        user.Handle,
 		ID:            user.ID,
 		PremiumMember: user.PremiumMember,
 		Token:         accessToken,
@@ -115,12 +167,6 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	user, err := uh.Database.GetUserByID(id)
-	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, "User not found")
-		return
-	}
-
 	var updateRequest models.UpdateUserRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
@@ -128,10 +174,68 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user.Email = updateRequest.Email
-	user.Password, err = bcrypt.GenerateFromPassword([]byte(updateRequest.Password), bcrypt.DefaultCost)
+	if err := validateHandle(updateRequest.Handle); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := validateProfile(stringValue(updateRequest.DisplayName), stringValue(updateRequest.Bio), stringValue(updateRequest.AvatarURL)); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateRequest.Password), bcrypt.DefaultCost)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
 
-	if err := uh.Database.UpdateUser(user); err != nil {
+	var user models.User
+	err = uh.Database.Tx(func(tx database.Tx) error {
+		var err error
+		user, err = tx.GetUserByID(id)
+		if err != nil {
+			return errUserNotFound
+		}
+
+		if existing, err := tx.GetUserByEmail(updateRequest.Email); err == nil && existing.ID != user.ID {
+			return errEmailTaken
+		}
+
+		if updateRequest.Handle != "" {
+			if existing, err := tx.GetUserByHandle(updateRequest.Handle); err == nil && existing.ID != user.ID {
+				return errHandleTaken
+			}
+			user.Handle = updateRequest.Handle
+		}
+
+		if updateRequest.DisplayName != nil {
+			user.DisplayName = *updateRequest.DisplayName
+		}
+		if updateRequest.Bio != nil {
+			user.Bio = *updateRequest.Bio
+		}
+		if updateRequest.AvatarURL != nil {
+			user.AvatarURL = *updateRequest.AvatarURL
+		}
+
+		user.Email = updateRequest.Email
+		user.Password = hashedPassword
+
+		return tx.UpdateUser(user)
+	})
+
+	switch {
+	case errors.Is(err, errUserNotFound):
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	case errors.Is(err, errEmailTaken):
+		utils.WriteError(w, http.StatusConflict, "Email already exists")
+		return
+	case errors.Is(err, errHandleTaken):
+		utils.WriteError(w, http.StatusConflict, "Handle already exists")
+		return
+	case err != nil:
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
@@ -139,12 +243,48 @@ func (uh *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	response := models.UpdateUserResponse{
 		ID:            user.ID,
 		Email:         user.Email,
+		Handle:        user.Handle,
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		AvatarURL:     user.AvatarURL,
 		PremiumMember: user.PremiumMember,
 	}
 
 	utils.WriteData(w, http.StatusOK, response)
 }
 
+func (uh *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tokenString, err := utils.ExtractTokenFromAuthHeader(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := utils.ValidateAccessToken(tokenString, uh.Config)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	if err := uh.Database.DeleteUser(id); err != nil {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.WriteData(w, http.StatusOK, nil)
+}
+
 func (uh *UserHandler) UpdatePremiumMembership(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		utils.WriteError(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -175,22 +315,36 @@ func (uh *UserHandler) UpdatePremiumMembership(w http.ResponseWriter, r *http.Re
 	}
 
 	userId := pwh.Data.UserID
-	user, err := uh.Database.GetUserByID(userId)
-	if err != nil {
-		utils.WriteError(w, http.StatusNotFound, "User not found")
-		return
-	}
+	err = uh.Database.Tx(func(tx database.Tx) error {
+		user, err := tx.GetUserByID(userId)
+		if err != nil {
+			return errUserNotFound
+		}
 
-	if user.PremiumMember {
-		utils.WriteData(w, http.StatusOK, nil)
+		if user.PremiumMember {
+			return nil
+		}
+
+		user.PremiumMember = true
+		return tx.UpdateUser(user)
+	})
+
+	if errors.Is(err, errUserNotFound) {
+		utils.WriteError(w, http.StatusNotFound, "User not found")
 		return
 	}
-
-	user.PremiumMember = true
-	if err := uh.Database.UpdateUser(user); err != nil {
+	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, "Failed to update user")
 		return
 	}
 
 	utils.WriteData(w, http.StatusOK, nil)
 }
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
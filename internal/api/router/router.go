@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/BrownieBrown/dolores/internal/api/handler"
+	"github.com/BrownieBrown/dolores/internal/media"
 	"net/http"
 )
 
@@ -13,27 +14,71 @@ func NewRouter() *Router {
 	return &Router{http.NewServeMux()}
 }
 
-func (r *Router) Init(ch *handler.ChirpHandler, hh *handler.HealthHandler, uh *handler.UserHandler, mh *handler.MetricsHandler) {
-	fileServerHandler := http.StripPrefix("/app/", http.FileServer(http.Dir(".")))
-	r.Handle("/app/", mh.IncrementFileServerHits(fileServerHandler))
+func (r *Router) Init(ch *handler.ChirpHandler, hh *handler.HealthHandler, uh *handler.UserHandler, mh *handler.MetricsHandler, bh *handler.BackupHandler, mdh *handler.MediaHandler) {
+	// Only the landing page and assets are public; the working directory
+	// also holds the database, its log and the backups.
+	indexHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeFile(w, req, "index.html")
+	})
+	r.Handle("/app/{$}", mh.IncrementFileServerHits(indexHandler))
 
 	assetHandler := http.StripPrefix("/app/assets/", http.FileServer(http.Dir("./assets")))
 	r.Handle("/app/assets/", mh.IncrementFileServerHits(assetHandler))
 
+	mediaHandler := http.StripPrefix(media.URLPrefix, http.FileServer(media.FileSystem(mdh.Config.MediaDir)))
+	r.Handle(media.URLPrefix, mh.IncrementFileServerHits(mediaHandler))
+
 	r.HandleFunc("GET /api/healthz", hh.GetHealth)
 
 	r.HandleFunc("GET /admin/metrics", mh.GetFileServerHits)
 
 	r.HandleFunc("GET /api/reset", mh.ResetFileServerHits)
 
+	r.HandleFunc("POST /admin/backups", bh.CreateBackup)
+	r.HandleFunc("GET /admin/backups", bh.ListBackups)
+	r.HandleFunc("POST /admin/backups/{name}/restore", bh.RestoreBackup)
+
 	r.HandleFunc("POST /api/chirps", ch.CreateChirp)
 	r.HandleFunc("GET /api/chirps", ch.GetChirps)
 	r.HandleFunc("GET /api/chirps/{id}", ch.GetChirp)
 	r.HandleFunc("DELETE /api/chirps/{id}", ch.DeleteChirp)
+	r.HandleFunc("PUT /api/chirps/{id}", ch.EditChirp)
+	r.HandleFunc("PATCH /api/chirps/{id}", ch.EditChirp)
+	r.HandleFunc("GET /api/chirps/{id}/revisions", ch.GetChirpRevisions)
+	r.HandleFunc("GET /api/chirps/{id}/replies", ch.GetReplies)
+	r.HandleFunc("GET /api/chirps/{id}/thread", ch.GetThread)
+	r.HandleFunc("POST /api/chirps/{id}/likes", ch.LikeChirp)
+	r.HandleFunc("DELETE /api/chirps/{id}/likes", ch.UnlikeChirp)
+	r.HandleFunc("POST /api/chirps/{id}/rechirp", ch.Rechirp)
+	r.HandleFunc("DELETE /api/chirps/{id}/rechirp", ch.UndoRechirp)
+	r.HandleFunc("POST /api/chirps/{id}/bookmark", ch.BookmarkChirp)
+	r.HandleFunc("DELETE /api/chirps/{id}/bookmark", ch.UnbookmarkChirp)
+	r.HandleFunc("POST /api/chirps/{id}/pin", ch.PinChirp)
+	r.HandleFunc("DELETE /api/chirps/{id}/pin", ch.UnpinChirp)
+	r.HandleFunc("GET /api/users/{id}/likes", ch.GetUserLikes)
+	r.HandleFunc("GET /api/timeline", ch.GetTimeline)
+	r.HandleFunc("GET /api/bookmarks", ch.GetBookmarks)
+	r.HandleFunc("GET /api/tags/trending", ch.GetTrendingTags)
+	r.HandleFunc("GET /api/tags/{tag}/chirps", ch.GetTagChirps)
+	r.HandleFunc("GET /api/search", ch.SearchChirps)
+	r.HandleFunc("GET /api/drafts", ch.GetDrafts)
+	r.HandleFunc("PUT /api/drafts/{id}", ch.UpdateDraft)
+	r.HandleFunc("DELETE /api/drafts/{id}", ch.DeleteDraft)
+	r.HandleFunc("POST /api/drafts/{id}/publish", ch.PublishDraft)
+
+	r.HandleFunc("POST /api/media", mdh.UploadMedia)
 
 	r.HandleFunc("POST /api/users", uh.SignUp)
 	r.HandleFunc("POST /api/login", uh.SignIn)
 	r.HandleFunc("PUT /api/users", uh.UpdateUser)
+	r.HandleFunc("DELETE /api/users", uh.DeleteUser)
+	r.HandleFunc("GET /api/users/{handle}", uh.GetProfile)
+	r.HandleFunc("POST /api/users/{id}/follow", uh.Follow)
+	r.HandleFunc("DELETE /api/users/{id}/follow", uh.Unfollow)
+	r.HandleFunc("GET /api/users/{id}/followers", uh.GetFollowers)
+	r.HandleFunc("GET /api/users/{id}/following", uh.GetFollowing)
+	r.HandleFunc("GET /api/notifications", uh.GetNotifications)
+	r.HandleFunc("POST /api/notifications/read", uh.MarkNotificationsRead)
 
 	r.HandleFunc("POST /api/refresh", uh.RefreshToken)
 	r.HandleFunc("POST /api/revoke", uh.InvalidateRefreshToken)
@@ -0,0 +1,270 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	filePrefix    = "dolores-"
+	fileSuffix    = ".tar.gz"
+	timeFormat    = "20060102T150405.000Z"
+	manifestEntry = "manifest.json"
+	dataEntry     = "database.json"
+)
+
+type Manager struct {
+	store     database.Store
+	dir       string
+	retention int
+}
+
+// Info describes a snapshot archive. It doubles as the archive's manifest.
+type Info struct {
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	SchemaVersion int       `json:"schema_version"`
+	Size          int64     `json:"size"`
+	SHA256        string    `json:"sha256"`
+}
+
+// NewManager keeps snapshots of store in dir. When retention is positive
+// only the newest retention snapshots are kept.
+func NewManager(store database.Store, dir string, retention int) *Manager {
+	return &Manager{store: store, dir: dir, retention: retention}
+}
+
+// Create writes a compressed snapshot of the store and prunes old ones.
+func (m *Manager) Create() (Info, error) {
+	dbContent, err := m.store.Snapshot()
+	if err != nil {
+		return Info{}, err
+	}
+
+	data, err := json.Marshal(dbContent)
+	if err != nil {
+		return Info{}, err
+	}
+
+	createdAt := time.Now().UTC()
+	sum := sha256.Sum256(data)
+	info := Info{
+		Name:          filePrefix + createdAt.Format(timeFormat) + fileSuffix,
+		CreatedAt:     createdAt,
+		SchemaVersion: dbContent.Version,
+		Size:          int64(len(data)),
+		SHA256:        hex.EncodeToString(sum[:]),
+	}
+
+	archive, err := writeArchive(info, data)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return Info{}, err
+	}
+
+	if err := database.WriteFileAtomic(filepath.Join(m.dir, info.Name), archive, 0600); err != nil {
+		return Info{}, err
+	}
+
+	if err := m.prune(); err != nil {
+		return info, err
+	}
+
+	return info, nil
+}
+
+// List returns the snapshots in dir, newest first.
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]Info, 0, len(entries))
+	for _, entry := range entries {
+		if !isArchiveName(entry.Name()) {
+			continue
+		}
+
+		info, _, err := readArchive(filepath.Join(m.dir, entry.Name()), false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		info.Name = entry.Name()
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+
+	return infos, nil
+}
+
+// Restore verifies the named snapshot and replaces the store's content
+// with it.
+func (m *Manager) Restore(name string) (Info, error) {
+	if !isArchiveName(name) || filepath.Base(name) != name {
+		return Info{}, errors.New("invalid snapshot name")
+	}
+
+	return RestoreFile(m.store, filepath.Join(m.dir, name))
+}
+
+// RestoreFile verifies the archive at path and replaces the store's content
+// with it. Snapshots of an older schema version are migrated first.
+func RestoreFile(store database.Store, path string) (Info, error) {
+	info, data, err := readArchive(path, true)
+	if err != nil {
+		return Info{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	if int64(len(data)) != info.Size || hex.EncodeToString(sum[:]) != info.SHA256 {
+		return Info{}, errors.New("snapshot checksum mismatch")
+	}
+
+	dbContent, err := database.ParseSnapshot(data)
+	if err != nil {
+		return Info{}, err
+	}
+
+	if err := store.Restore(dbContent); err != nil {
+		return Info{}, err
+	}
+
+	return info, nil
+}
+
+func (m *Manager) prune() error {
+	if m.retention <= 0 {
+		return nil
+	}
+
+	infos, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	if len(infos) <= m.retention {
+		return nil
+	}
+
+	for _, info := range infos[m.retention:] {
+		if err := os.Remove(filepath.Join(m.dir, info.Name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isArchiveName(name string) bool {
+	return strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix)
+}
+
+func writeArchive(info Info, data []byte) ([]byte, error) {
+	manifest, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, file := range []struct {
+		name string
+		data []byte
+	}{{manifestEntry, manifest}, {dataEntry, data}} {
+		header := &tar.Header{Name: file.name, Mode: 0600, Size: int64(len(file.data)), ModTime: info.CreatedAt}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readArchive returns the manifest of the archive at path and, if withData
+// is set, the serialized database stored in it.
+func readArchive(path string, withData bool) (Info, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Info{}, nil, err
+	}
+	defer gz.Close()
+
+	var info Info
+	var manifest, data []byte
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Info{}, nil, err
+		}
+
+		switch header.Name {
+		case manifestEntry:
+			manifest, err = io.ReadAll(tr)
+		case dataEntry:
+			data, err = io.ReadAll(tr)
+		}
+		if err != nil {
+			return Info{}, nil, err
+		}
+
+		if manifest != nil && (data != nil || !withData) {
+			break
+		}
+	}
+
+	if manifest == nil || (withData && data == nil) {
+		return Info{}, nil, errors.New("snapshot archive is incomplete")
+	}
+
+	if err := json.Unmarshal(manifest, &info); err != nil {
+		return Info{}, nil, err
+	}
+
+	return info, data, nil
+}
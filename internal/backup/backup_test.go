@@ -0,0 +1,203 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func seededStore(t *testing.T) *database.DB {
+	t.Helper()
+
+	store := database.NewMemoryDB()
+	user, err := store.CreateUser(models.SignUpRequest{Email: "alice@example.com", Handle: "alice", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateChirp(models.Chirp{Body: "backed up #once", AuthorID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func snapshot(t *testing.T, store database.Store) database.DBStructure {
+	t.Helper()
+
+	dbContent, err := store.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dbContent
+}
+
+// writeTestArchive stores data as the named archive in dir, with a manifest
+// claiming size and sum.
+func writeTestArchive(t *testing.T, dir, name string, version int, data []byte, size int64, sum string) {
+	t.Helper()
+
+	archive, err := writeArchive(Info{CreatedAt: time.Now().UTC(), SchemaVersion: version, Size: size, SHA256: sum}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), archive, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRoundTrip(t *testing.T) {
+	store := seededStore(t)
+	want := snapshot(t, store)
+	m := NewManager(store, t.TempDir(), 0)
+
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SchemaVersion != database.SchemaVersion() {
+		t.Errorf("snapshot has schema version %d, want %d", info.SchemaVersion, database.SchemaVersion())
+	}
+
+	if _, err := store.CreateChirp(models.Chirp{Body: "after the backup", AuthorID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Restore(info.Name); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t, store); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored store differs from the one backed up:\n got %+v\nwant %+v", got, want)
+	}
+
+	// A fresh store restored from the file alone ends up the same.
+	fresh := database.NewMemoryDB()
+	if _, err := RestoreFile(fresh, filepath.Join(m.dir, info.Name)); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(t, fresh); !reflect.DeepEqual(got, want) {
+		t.Errorf("store restored from the file differs from the one backed up")
+	}
+}
+
+func TestRestoreRejectsCorruptArchive(t *testing.T) {
+	store := seededStore(t)
+	want := snapshot(t, store)
+	dir := t.TempDir()
+	m := NewManager(store, dir, 0)
+
+	info, err := m.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := readArchive(filepath.Join(dir, info.Name), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := []byte(strings.Replace(string(data), "backed up", "tampered!", 1))
+	writeTestArchive(t, dir, filePrefix+"tampered"+fileSuffix, info.SchemaVersion, tampered, info.Size, info.SHA256)
+	if err := os.WriteFile(filepath.Join(dir, filePrefix+"garbage"+fileSuffix), []byte("not gzip"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{filePrefix + "tampered" + fileSuffix, filePrefix + "garbage" + fileSuffix} {
+		if _, err := m.Restore(name); err == nil {
+			t.Errorf("%s was restored", name)
+		}
+	}
+	if got := snapshot(t, store); !reflect.DeepEqual(got, want) {
+		t.Error("a rejected archive changed the store")
+	}
+}
+
+func TestPruneKeepsNewest(t *testing.T) {
+	const retention = 3
+
+	dir := t.TempDir()
+	m := NewManager(seededStore(t), dir, retention)
+
+	var created []string
+	for i := 0; i < retention+2; i++ {
+		info, err := m.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, info.Name)
+		// Archive names have millisecond precision.
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	slices.Sort(names)
+	if want := created[len(created)-retention:]; !slices.Equal(names, want) {
+		t.Errorf("kept %v, want the newest %d: %v", names, retention, want)
+	}
+}
+
+// TestRestoreMigratesOldSnapshot restores an archive of the unversioned
+// layout written before migrations existed.
+func TestRestoreMigratesOldSnapshot(t *testing.T) {
+	data := []byte(`{
+		"chirps": {
+			"1": {"id": 1, "body": "Learning #Go today", "author_id": 1},
+			"4": {"id": 4, "body": "No tags here", "author_id": 1}
+		},
+		"users": {"1": {"id": 1, "email": "alice@example.com", "password": "aGFzaA==", "is_chirpy_red": true}},
+		"invalid_refresh_tokens": {}
+	}`)
+
+	dir := t.TempDir()
+	name := filePrefix + "v0" + fileSuffix
+	writeTestArchive(t, dir, name, 0, data, int64(len(data)), checksum(data))
+
+	store := database.NewMemoryDB()
+	info, err := NewManager(store, dir, 0).Restore(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SchemaVersion != 0 {
+		t.Errorf("manifest has schema version %d, want 0", info.SchemaVersion)
+	}
+
+	chirp, err := store.GetChirp("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.Kind != models.ChirpKindChirp || !slices.Equal(chirp.Tags, []string{"go"}) {
+		t.Errorf("migrated chirp is %+v, want a plain chirp tagged go", chirp)
+	}
+	if user, err := store.GetUserByID(1); err != nil || !user.PremiumMember {
+		t.Errorf("migrated user is %+v, %v", user, err)
+	}
+
+	next, err := store.CreateChirp(models.Chirp{Body: "after the restore", AuthorID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID != 5 {
+		t.Errorf("new chirp got ID %d, want 5", next.ID)
+	}
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type ApiConfig struct {
@@ -9,13 +12,108 @@ type ApiConfig struct {
 	AccessTokenIssuer  string
 	RefreshTokenIssuer string
 	PolkaAPIKey        string
+	AdminAPIKey        string
+	DatabaseDriver     string
+	DatabaseMode       string
+	DatabasePath       string
+	DatabaseSeedPath   string
+	DatabaseDurability string
+	DatabaseFlush      time.Duration
+	Port               string
+	BackupDir          string
+	BackupRetention    int
+	// ChirpEditWindow is how long after posting a chirp can be edited;
+	// Chirpy Red members get ChirpEditWindowRed instead.
+	ChirpEditWindow    time.Duration
+	ChirpEditWindowRed time.Duration
+	// TagTrendingWindow is how far back trending hashtags look; a use counts
+	// half as much every TagTrendingHalfLife.
+	TagTrendingWindow   time.Duration
+	TagTrendingHalfLife time.Duration
+	// MediaDir holds uploaded images, served under /app/media/.
+	MediaDir         string
+	MediaMaxBytes    int
+	MediaMaxPerChirp int
+	// SchedulerInterval is how often scheduled chirps that are due get
+	// published.
+	SchedulerInterval time.Duration
 }
 
 func LoadConfig() *ApiConfig {
-	return &ApiConfig{
-		JwtSecret:          os.Getenv("JWT_SECRET"),
-		AccessTokenIssuer:  os.Getenv("ACCESS_TOKEN_ISSUER"),
-		RefreshTokenIssuer: os.Getenv("REFRESH_TOKEN_ISSUER"),
-		PolkaAPIKey:        os.Getenv("POLKA_API_KEY"),
+	cfg := &ApiConfig{
+		JwtSecret:           os.Getenv("JWT_SECRET"),
+		AccessTokenIssuer:   os.Getenv("ACCESS_TOKEN_ISSUER"),
+		RefreshTokenIssuer:  os.Getenv("REFRESH_TOKEN_ISSUER"),
+		PolkaAPIKey:         os.Getenv("POLKA_API_KEY"),
+		AdminAPIKey:         os.Getenv("ADMIN_API_KEY"),
+		DatabaseDriver:      getEnvOrDefault("DB_DRIVER", "json"),
+		DatabaseMode:        getEnvOrDefault("DB_MODE", "persistent"),
+		DatabasePath:        os.Getenv("DB_PATH"),
+		DatabaseSeedPath:    os.Getenv("DB_SEED_PATH"),
+		DatabaseDurability:  getEnvOrDefault("DB_DURABILITY", "sync"),
+		DatabaseFlush:       getDurationOrDefault("DB_FLUSH_INTERVAL", time.Second),
+		Port:                getEnvOrDefault("PORT", "8080"),
+		BackupDir:           getEnvOrDefault("BACKUP_DIR", "./backups"),
+		BackupRetention:     getIntOrDefault("BACKUP_RETENTION", 7),
+		ChirpEditWindow:     getDurationOrDefault("CHIRP_EDIT_WINDOW", 15*time.Minute),
+		ChirpEditWindowRed:  getDurationOrDefault("CHIRP_EDIT_WINDOW_RED", time.Hour),
+		TagTrendingWindow:   getDurationOrDefault("TAG_TRENDING_WINDOW", 24*time.Hour),
+		TagTrendingHalfLife: getDurationOrDefault("TAG_TRENDING_HALF_LIFE", 6*time.Hour),
+		MediaDir:            getEnvOrDefault("MEDIA_DIR", "./media"),
+		MediaMaxBytes:       getIntOrDefault("MEDIA_MAX_BYTES", 5<<20),
+		MediaMaxPerChirp:    getIntOrDefault("MEDIA_MAX_PER_CHIRP", 4),
+		SchedulerInterval:   getDurationOrDefault("SCHEDULER_INTERVAL", 15*time.Second),
 	}
+
+	if cfg.DatabasePath == "" {
+		cfg.DatabasePath = defaultDatabasePath(cfg.DatabaseDriver)
+	}
+
+	return cfg
+}
+
+func getIntOrDefault(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("config: invalid %s %q, using %d", key, value, fallback)
+		return fallback
+	}
+
+	return n
+}
+
+func getDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("config: invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+
+	return d
+}
+
+func defaultDatabasePath(driver string) string {
+	if driver == "sqlite" {
+		return "./database.db"
+	}
+
+	return "./database.json"
+}
+
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
 }
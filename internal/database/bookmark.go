@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"time"
+)
+
+func bookmarkKey(chirpID, userID int) string {
+	return fmt.Sprintf("%d:%d", chirpID, userID)
+}
+
+// bookmarkedAt positions chirps bookmarked by userID by when they were
+// bookmarked. It looks the times up once rather than on every comparison.
+func (db *DB) bookmarkedAt(userID int, chirps []models.Chirp) chirpKey {
+	times := make(map[int]time.Time, len(chirps))
+	for _, chirp := range chirps {
+		times[chirp.ID] = db.data.Bookmarks[bookmarkKey(chirp.ID, userID)].CreatedAt
+	}
+
+	return func(chirp models.Chirp) cursor {
+		return cursor{CreatedAt: times[chirp.ID], ID: chirp.ID}
+	}
+}
+
+func (db *DB) BookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.BookmarkChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) BookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := tx.db.getChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	key := bookmarkKey(chirp.ID, userID)
+	if _, ok := tx.db.data.Bookmarks[key]; ok {
+		return chirp, nil
+	}
+
+	if err := tx.put("bookmarks", key, models.Bookmark{ChirpID: chirp.ID, UserID: userID, CreatedAt: time.Now().UTC()}); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (db *DB) UnbookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnbookmarkChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) UnbookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := tx.db.getChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	key := bookmarkKey(chirp.ID, userID)
+	if _, ok := tx.db.data.Bookmarks[key]; !ok {
+		return chirp, nil
+	}
+
+	if err := tx.delete("bookmarks", key); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// deleteBookmarks drops every bookmark of a chirp that is being deleted.
+func (tx *dbTx) deleteBookmarks(chirpID int) error {
+	var keys []string
+	for userID := range tx.db.indexes.bookmarksByChirp[chirpID] {
+		keys = append(keys, bookmarkKey(chirpID, userID))
+	}
+
+	return tx.deleteBookmarkKeys(keys)
+}
+
+// deleteUserBookmarks drops every bookmark of a user that is being deleted.
+func (tx *dbTx) deleteUserBookmarks(userID int) error {
+	var keys []string
+	for chirpID := range tx.db.indexes.bookmarksByUser[userID] {
+		keys = append(keys, bookmarkKey(chirpID, userID))
+	}
+
+	return tx.deleteBookmarkKeys(keys)
+}
+
+func (tx *dbTx) deleteBookmarkKeys(keys []string) error {
+	for _, key := range keys {
+		if err := tx.delete("bookmarks", key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestBookmarksPagedByBookmarkTime bookmarks chirps in a different order from
+// the one they were posted in. The list follows the bookmarks, page by page.
+func TestBookmarksPagedByBookmarkTime(t *testing.T) {
+	sqlite, err := NewSQLiteDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dbContent := newDBStructure()
+	dbContent.Users[1] = models.User{ID: 1, Email: "reader@example.com", Password: []byte("hash")}
+	for id := 1; id <= 4; id++ {
+		createdAt := start.Add(time.Duration(id) * time.Hour)
+		dbContent.Chirps[id] = models.Chirp{ID: id, Kind: models.ChirpKindChirp, Body: "chirp", AuthorID: 1, CreatedAt: createdAt, UpdatedAt: createdAt}
+	}
+	// Chirps 1 and 4 were bookmarked at the same time; the ID breaks the tie.
+	for minute, chirpID := range []int{2, 1, 3} {
+		dbContent.Bookmarks[bookmarkKey(chirpID, 1)] = models.Bookmark{ChirpID: chirpID, UserID: 1, CreatedAt: start.Add(time.Duration(24*60+minute) * time.Minute)}
+	}
+	dbContent.Bookmarks[bookmarkKey(4, 1)] = models.Bookmark{ChirpID: 4, UserID: 1, CreatedAt: dbContent.Bookmarks[bookmarkKey(1, 1)].CreatedAt}
+	dbContent.Sequences["users"] = 1
+	dbContent.Sequences["chirps"] = 4
+
+	for name, store := range map[string]Store{"json": NewMemoryDB(), "sqlite": sqlite} {
+		if err := store.Restore(dbContent); err != nil {
+			t.Fatal(name, err)
+		}
+
+		for _, order := range []struct {
+			sortOrder string
+			want      []int
+		}{
+			{"desc", []int{3, 4, 1, 2}},
+			{"asc", []int{2, 1, 4, 3}},
+		} {
+			query := ChirpQuery{BookmarkedBy: 1, SortOrder: order.sortOrder, Limit: 1}
+			var ids []int
+			var last ChirpPage
+			for {
+				page, err := store.ListChirps(query)
+				if err != nil {
+					t.Fatal(name, err)
+				}
+				for _, chirp := range page.Chirps {
+					ids = append(ids, chirp.ID)
+				}
+				last = page
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			if !slices.Equal(ids, order.want) {
+				t.Errorf("%s: %s bookmarks paged as %v, want %v", name, order.sortOrder, ids, order.want)
+			}
+
+			query.Cursor = last.PrevCursor
+			page, err := store.ListChirps(query)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			if len(page.Chirps) != 1 || page.Chirps[0].ID != order.want[len(order.want)-2] {
+				t.Errorf("%s: %s page before the last is %v, want chirp %d", name, order.sortOrder, page.Chirps, order.want[len(order.want)-2])
+			}
+		}
+	}
+}
@@ -3,143 +3,412 @@ package database
 import (
 	"errors"
 	"github.com/BrownieBrown/dolores/internal/models"
+	"slices"
 	"sort"
 	"strconv"
+	"time"
 )
 
+var ErrChirpNotFound = errors.New("chirp not found")
+
 func (db *DB) CreateChirp(chirp models.Chirp) (models.Chirp, error) {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	var newChirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		newChirp, err = tx.CreateChirp(chirp)
+		return err
+	})
+
+	return newChirp, err
+}
 
-	dbContent, err := db.loadDB()
+func (tx *dbTx) CreateChirp(chirp models.Chirp) (models.Chirp, error) {
+	id, err := tx.nextID("chirps")
 	if err != nil {
 		return models.Chirp{}, err
 	}
 
-	lastChirpID := len(dbContent.Chirps)
-	newChirp := models.Chirp{ID: lastChirpID + 1, Body: chirp.Body, AuthorID: chirp.AuthorID}
-	dbContent.Chirps[newChirp.ID] = newChirp
+	now := time.Now().UTC()
+	kind := chirp.Kind
+	if kind == "" {
+		kind = models.ChirpKindChirp
+	}
 
-	if err = db.writeDB(dbContent); err != nil {
+	newChirp := models.Chirp{ID: id, Kind: kind, Body: chirp.Body, Tags: parseTags(chirp.Body), Media: chirp.Media, AuthorID: chirp.AuthorID, OriginalID: chirp.OriginalID, CreatedAt: now, UpdatedAt: now, InReplyTo: chirp.InReplyTo}
+	if err := tx.put("chirps", strconv.Itoa(newChirp.ID), newChirp); err != nil {
 		return models.Chirp{}, err
+	}
 
+	if err := notifyNewChirp(tx, newChirp); err != nil {
+		return models.Chirp{}, err
 	}
 
 	return newChirp, nil
 }
 
-func (db *DB) GetChirps(sortOrder string) ([]models.Chirp, error) {
+func (db *DB) ListChirps(query ChirpQuery) (ChirpPage, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return []models.Chirp{}, err
+	var chirps []models.Chirp
+	if authorIDs, ok := db.queryAuthors(query); ok {
+		for _, authorID := range authorIDs {
+			for chirpID := range db.indexes.chirpsByAuthor[authorID] {
+				if chirp := db.data.Chirps[chirpID]; query.matches(chirp) {
+					chirps = append(chirps, chirp)
+				}
+			}
+		}
+	} else if query.BookmarkedBy != 0 {
+		for chirpID := range db.indexes.bookmarksByUser[query.BookmarkedBy] {
+			if chirp := db.data.Chirps[chirpID]; query.matches(chirp) {
+				chirps = append(chirps, chirp)
+			}
+		}
+	} else if query.Tag != "" {
+		for chirpID := range db.indexes.tagged[query.Tag] {
+			if chirp := db.data.Chirps[chirpID]; query.matches(chirp) {
+				chirps = append(chirps, chirp)
+			}
+		}
+	} else {
+		for _, chirp := range db.data.Chirps {
+			if query.matches(chirp) {
+				chirps = append(chirps, chirp)
+			}
+		}
+	}
+
+	// Bookmarks are not in matches, which only sees the chirp.
+	if query.BookmarkedBy != 0 {
+		chirps = slices.DeleteFunc(chirps, func(chirp models.Chirp) bool {
+			_, ok := db.indexes.bookmarksByUser[query.BookmarkedBy][chirp.ID]
+			return !ok
+		})
+	}
+
+	key := byCreatedAt
+	if query.BookmarkedBy != 0 {
+		key = db.bookmarkedAt(query.BookmarkedBy, chirps)
 	}
+	sortByKey(chirps, key, query.SortOrder == "desc")
 
-	chirps := make([]models.Chirp, 0, len(dbContent.Chirps))
-	for _, chirp := range dbContent.Chirps {
-		chirps = append(chirps, chirp)
+	return paginate(chirps, query, key)
+}
+
+// queryAuthors returns the authors query is limited to, combining AuthorIDs
+// with TimelineOf. ok is false if chirps by anyone match.
+func (db *DB) queryAuthors(query ChirpQuery) (authorIDs []int, ok bool) {
+	if query.TimelineOf == 0 {
+		return query.AuthorIDs, len(query.AuthorIDs) > 0
 	}
 
-	if sortOrder == "desc" {
-		sortDescending(chirps)
-		return chirps, nil
+	following := db.indexes.following[query.TimelineOf]
+	if len(query.AuthorIDs) > 0 {
+		for _, authorID := range query.AuthorIDs {
+			if _, ok := following[authorID]; ok || authorID == query.TimelineOf {
+				authorIDs = append(authorIDs, authorID)
+			}
+		}
+		return authorIDs, true
 	}
 
-	sortAscending(chirps)
+	authorIDs = append(authorIDs, query.TimelineOf)
+	for followeeID := range following {
+		authorIDs = append(authorIDs, followeeID)
+	}
 
-	return chirps, nil
+	return authorIDs, true
 }
 
 func (db *DB) GetChirp(id string) (models.Chirp, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
-	dbContent, err := db.loadDB()
+	return db.getChirp(id)
+}
+
+func (tx *dbTx) GetChirp(id string) (models.Chirp, error) {
+	return tx.db.getChirp(id)
+}
+
+func (db *DB) getChirp(id string) (models.Chirp, error) {
+	intID, err := strconv.Atoi(id)
 	if err != nil {
 		return models.Chirp{}, err
+
 	}
 
-	intID, err := strconv.Atoi(id)
+	chirp, ok := db.data.Chirps[intID]
+	if !ok {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	return chirp, nil
+}
+
+func (db *DB) GetChirpsByID(ids []int) (map[int]models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getChirpsByID(ids), nil
+}
+
+func (tx *dbTx) GetChirpsByID(ids []int) (map[int]models.Chirp, error) {
+	return tx.db.getChirpsByID(ids), nil
+}
+
+func (db *DB) getChirpsByID(ids []int) map[int]models.Chirp {
+	chirps := make(map[int]models.Chirp, len(ids))
+	for _, id := range ids {
+		if chirp, ok := db.data.Chirps[id]; ok {
+			chirps[id] = chirp
+		}
+	}
+
+	return chirps
+}
+
+func (db *DB) GetRechirp(originalID string, userID int) (models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getRechirp(originalID, userID)
+}
+
+func (tx *dbTx) GetRechirp(originalID string, userID int) (models.Chirp, error) {
+	return tx.db.getRechirp(originalID, userID)
+}
+
+func (db *DB) getRechirp(originalID string, userID int) (models.Chirp, error) {
+	id, err := strconv.Atoi(originalID)
 	if err != nil {
 		return models.Chirp{}, err
-
 	}
 
-	for _, chirp := range dbContent.Chirps {
-		if chirp.ID == intID {
-			return chirp, nil
+	for rechirpID := range db.indexes.rechirps[id] {
+		if rechirp := db.data.Chirps[rechirpID]; rechirp.AuthorID == userID {
+			return rechirp, nil
 		}
 	}
 
-	return models.Chirp{}, errors.New("chirp not found")
+	return models.Chirp{}, ErrChirpNotFound
 }
 
 func (db *DB) DeleteChirp(id string) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	return db.Tx(func(tx Tx) error {
+		return tx.DeleteChirp(id)
+	})
+}
 
-	dbContent, err := db.loadDB()
+func (tx *dbTx) DeleteChirp(id string) error {
+	intID, err := strconv.Atoi(id)
 	if err != nil {
 		return err
 	}
 
-	intID, err := strconv.Atoi(id)
-	if err != nil {
+	chirp, ok := tx.db.data.Chirps[intID]
+	if !ok || chirp.Deleted {
+		return ErrChirpNotFound
+	}
+
+	if _, ok := tx.db.data.Revisions[intID]; ok {
+		if err := tx.delete("revisions", strconv.Itoa(intID)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.deleteLikes(intID); err != nil {
 		return err
 	}
 
-	if _, ok := dbContent.Chirps[intID]; !ok {
-		return errors.New("chirp not found")
+	if err := tx.deleteBookmarks(intID); err != nil {
+		return err
+	}
+
+	if err := tx.deleteNotifications(notificationIDs(tx.db.indexes.notificationsByChirp, intID)); err != nil {
+		return err
 	}
 
-	delete(dbContent.Chirps, intID)
+	rechirpIDs := make([]int, 0, len(tx.db.indexes.rechirps[intID]))
+	for rechirpID := range tx.db.indexes.rechirps[intID] {
+		rechirpIDs = append(rechirpIDs, rechirpID)
+	}
+
+	for _, rechirpID := range rechirpIDs {
+		if err := tx.DeleteChirp(strconv.Itoa(rechirpID)); err != nil {
+			return err
+		}
+	}
 
-	if err = db.writeDB(dbContent); err != nil {
+	if len(tx.db.indexes.replies[intID]) > 0 {
+		return tx.put("chirps", strconv.Itoa(intID), tombstone(chirp))
+	}
+
+	if err := tx.delete("chirps", strconv.Itoa(intID)); err != nil {
 		return err
 	}
 
+	return tx.pruneTombstones(chirp.InReplyTo)
+}
+
+// pruneTombstones removes the tombstones above id in its thread that have no
+// replies left.
+func (tx *dbTx) pruneTombstones(id int) error {
+	for id != 0 {
+		chirp, ok := tx.db.data.Chirps[id]
+		if !ok || !chirp.Deleted || len(tx.db.indexes.replies[id]) > 0 {
+			return nil
+		}
+
+		if err := tx.delete("chirps", strconv.Itoa(id)); err != nil {
+			return err
+		}
+		id = chirp.InReplyTo
+	}
+
 	return nil
 }
 
-func (db *DB) GetChirpsByAuthorID(id string) ([]models.Chirp, error) {
+func (db *DB) GetReplies(id string) ([]models.Chirp, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
-	dbContent, err := db.loadDB()
+	return db.getReplies(id)
+}
+
+func (tx *dbTx) GetReplies(id string) ([]models.Chirp, error) {
+	return tx.db.getReplies(id)
+}
+
+func (db *DB) getReplies(id string) ([]models.Chirp, error) {
+	chirp, err := db.getChirp(id)
 	if err != nil {
 		return []models.Chirp{}, err
 	}
 
+	replyIDs := db.indexes.replies[chirp.ID]
+	replies := make([]models.Chirp, 0, len(replyIDs))
+	for replyID := range replyIDs {
+		replies = append(replies, db.data.Chirps[replyID])
+	}
+
+	return sortAscending(replies), nil
+}
+
+func (db *DB) GetThread(id string, depth int) (models.ChirpThread, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	// The transaction only reads, so the read lock is enough.
+	return buildThread(&dbTx{db: db}, id, depth)
+}
+
+func (db *DB) UpdateChirp(chirp models.Chirp) (models.Chirp, error) {
+	var updated models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		updated, err = tx.UpdateChirp(chirp)
+		return err
+	})
+
+	return updated, err
+}
+
+func (tx *dbTx) UpdateChirp(chirp models.Chirp) (models.Chirp, error) {
+	current, ok := tx.db.data.Chirps[chirp.ID]
+	if !ok || current.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if current.Body == chirp.Body {
+		return current, nil
+	}
+
+	revisions := slices.Clone(tx.db.data.Revisions[current.ID])
+	revisions = append(revisions, models.ChirpRevision{Revision: len(revisions) + 1, Body: current.Body, CreatedAt: current.UpdatedAt})
+	if err := tx.put("revisions", strconv.Itoa(current.ID), revisions); err != nil {
+		return models.Chirp{}, err
+	}
+
+	previousBody := current.Body
+	current.Body = chirp.Body
+	current.Tags = parseTags(chirp.Body)
+	current.Edited = true
+	current.UpdatedAt = time.Now().UTC()
+	if err := tx.put("chirps", strconv.Itoa(current.ID), current); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := notifyEditedChirp(tx, current, previousBody); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return current, nil
+}
+
+func (db *DB) GetChirpRevisions(id string) ([]models.ChirpRevision, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getChirpRevisions(id)
+}
+
+func (tx *dbTx) GetChirpRevisions(id string) ([]models.ChirpRevision, error) {
+	return tx.db.getChirpRevisions(id)
+}
+
+func (db *DB) getChirpRevisions(id string) ([]models.ChirpRevision, error) {
+	chirp, err := db.getChirp(id)
+	if err != nil {
+		return []models.ChirpRevision{}, err
+	}
+
+	if chirp.Deleted {
+		return []models.ChirpRevision{}, ErrChirpNotFound
+	}
+
+	revisions := slices.Clone(db.data.Revisions[chirp.ID])
+
+	return append(revisions, models.ChirpRevision{Revision: len(revisions) + 1, Body: chirp.Body, CreatedAt: chirp.UpdatedAt}), nil
+}
+
+func (db *DB) GetChirpsByAuthorID(id string) ([]models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getChirpsByAuthorID(id)
+}
+
+func (tx *dbTx) GetChirpsByAuthorID(id string) ([]models.Chirp, error) {
+	return tx.db.getChirpsByAuthorID(id)
+}
+
+func (db *DB) getChirpsByAuthorID(id string) ([]models.Chirp, error) {
 	authorID, err := strconv.Atoi(id)
 	if err != nil {
 		return []models.Chirp{}, err
 
 	}
 
-	chirps := make([]models.Chirp, 0, len(dbContent.Chirps))
-	for _, chirp := range dbContent.Chirps {
-		if chirp.AuthorID == authorID {
-			chirps = append(chirps, chirp)
-		}
+	chirpIDs := db.indexes.chirpsByAuthor[authorID]
+	chirps := make([]models.Chirp, 0, len(chirpIDs))
+	for chirpID := range chirpIDs {
+		chirps = append(chirps, db.data.Chirps[chirpID])
 	}
 
-	return chirps, nil
+	return sortAscending(chirps), nil
 }
 
 func sortAscending(chirps []models.Chirp) []models.Chirp {
 	sort.Slice(chirps, func(i, j int) bool {
-		return chirps[i].ID < chirps[j].ID
+		return chirpBefore(chirps[i], chirps[j])
 	})
 
 	return chirps
 }
 
-func sortDescending(chirps []models.Chirp) []models.Chirp {
-	sort.Slice(chirps, func(i, j int) bool {
-		return chirps[i].ID > chirps[j].ID
-	})
-
-	return chirps
+// chirpBefore orders chirps by creation time, then by ID.
+func chirpBefore(a, b models.Chirp) bool {
+	return byCreatedAt(a).before(byCreatedAt(b))
 }
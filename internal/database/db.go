@@ -3,31 +3,108 @@ package database
 import (
 	"encoding/json"
 	"github.com/BrownieBrown/dolores/internal/models"
+	"log"
+	"maps"
 	"os"
 	"sync"
 	"time"
 )
 
 type DB struct {
-	path string
-	mux  *sync.RWMutex
+	storage    storage
+	mux        *sync.RWMutex
+	data       DBStructure
+	indexes    indexes
+	durability Durability
+
+	// pending holds log entries not yet written to storage and dirty marks
+	// changes not yet folded into the snapshot. Both are only touched with
+	// mux held for writing, or by the flusher while it holds mux for reading.
+	pending []walEntry
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
 }
 
 type DBStructure struct {
+	Version              int                  `json:"version"`
 	Chirps               map[int]models.Chirp `json:"chirps"`
 	Users                map[int]models.User  `json:"users"`
 	InvalidRefreshTokens map[string]time.Time `json:"invalid_refresh_tokens"`
+	// Sequences holds the last ID handed out per table so IDs are never
+	// reused, even after the newest record has been deleted.
+	Sequences map[string]int `json:"sequences"`
+	// Revisions holds the superseded versions of edited chirps by chirp ID.
+	Revisions map[int][]models.ChirpRevision `json:"revisions"`
+	// Likes is keyed by likeKey.
+	Likes map[string]models.Like `json:"likes"`
+	// Follows is keyed by followKey.
+	Follows map[string]models.Follow `json:"follows"`
+	// Notifications is keyed by notification ID.
+	Notifications map[int]models.Notification `json:"notifications"`
+	// Drafts is keyed by draft ID.
+	Drafts map[int]models.Draft `json:"drafts"`
+	// Bookmarks is keyed by bookmarkKey.
+	Bookmarks map[string]models.Bookmark `json:"bookmarks"`
+}
+
+// Durability controls when changes held in memory reach the disk.
+type Durability struct {
+	// SyncEveryWrite fsyncs the operation log before a write returns. When
+	// false, log entries are batched and written on the next flush.
+	SyncEveryWrite bool
+	// FlushInterval is how often the snapshot is rewritten. Zero rewrites it
+	// on every write.
+	FlushInterval time.Duration
+}
+
+func NewDB(path string, durability Durability) (*DB, error) {
+	db := &DB{storage: newFileStorage(path), mux: &sync.RWMutex{}, durability: durability}
+
+	dbContent, err := db.loadDB()
+	if err != nil {
+		return nil, err
+	}
+	db.data = dbContent
+	db.indexes = buildIndexes(dbContent)
+
+	// Fold operations logged before a crash into a fresh snapshot.
+	if err := db.writeDB(); err != nil {
+		return nil, err
+	}
+
+	if durability.FlushInterval > 0 {
+		db.stop = make(chan struct{})
+		db.done = make(chan struct{})
+		go db.flushLoop(durability.FlushInterval)
+	}
+
+	return db, nil
+}
+
+func NewMemoryDB() *DB {
+	dbContent := newDBStructure()
+	return &DB{storage: &memoryStorage{}, mux: &sync.RWMutex{}, data: dbContent, indexes: buildIndexes(dbContent)}
 }
 
-func NewDB(path string) *DB {
-	return &DB{path: path, mux: &sync.RWMutex{}}
+func newDBStructure() DBStructure {
+	return DBStructure{Version: SchemaVersion(), Chirps: make(map[int]models.Chirp), Users: make(map[int]models.User), InvalidRefreshTokens: make(map[string]time.Time), Sequences: make(map[string]int), Revisions: make(map[int][]models.ChirpRevision), Likes: make(map[string]models.Like), Follows: make(map[string]models.Follow), Notifications: make(map[int]models.Notification), Drafts: make(map[int]models.Draft), Bookmarks: make(map[string]models.Bookmark)}
 }
 
 func (db *DB) loadDB() (DBStructure, error) {
-	dbContent := DBStructure{Chirps: make(map[int]models.Chirp), Users: make(map[int]models.User), InvalidRefreshTokens: make(map[string]time.Time)}
-	data, err := os.ReadFile(db.path)
-	if err == nil {
-		if err := json.Unmarshal(data, &dbContent); err != nil {
+	data, entries, err := db.storage.read()
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	dbContent, err := decodeDBStructure(data)
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	for _, entry := range entries {
+		if err := dbContent.apply(entry); err != nil {
 			return DBStructure{}, err
 		}
 	}
@@ -35,20 +112,175 @@ func (db *DB) loadDB() (DBStructure, error) {
 	return dbContent, nil
 }
 
-func (db *DB) writeDB(dbContent DBStructure) error {
-	data, err := json.Marshal(dbContent)
+// applyEntry applies entry to the resident data and its indexes. The caller
+// must hold mux for writing.
+func (db *DB) applyEntry(entry walEntry) error {
+	db.unindex(entry)
+	err := db.data.apply(entry)
+	db.index(entry)
+
+	return err
+}
+
+// persist records entries that have already been applied. The caller must
+// hold mux for writing.
+func (db *DB) persist(entries []walEntry) error {
+	if db.durability.SyncEveryWrite || db.durability.FlushInterval == 0 {
+		if err := db.storage.log(entries); err != nil {
+			return err
+		}
+	} else {
+		db.pending = append(db.pending, entries...)
+	}
+	db.dirty = true
+
+	if db.durability.FlushInterval == 0 {
+		// The entries are already in the log, which the next flush or
+		// restart folds into the snapshot, so the write itself succeeded.
+		if err := db.flush(); err != nil {
+			log.Printf("database: flush failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// flush writes batched log entries and, if anything changed, a new snapshot.
+// The batch is logged as one record, so a crash keeps all of its transactions
+// or none. The caller must hold mux, for reading at least, so no writer can
+// interleave.
+func (db *DB) flush() error {
+	if len(db.pending) > 0 {
+		if err := db.storage.log(db.pending); err != nil {
+			return err
+		}
+		db.pending = nil
+	}
+
+	if !db.dirty {
+		return nil
+	}
+
+	return db.writeDB()
+}
+
+func (db *DB) writeDB() error {
+	data, err := json.Marshal(db.data)
 	if err != nil {
 		return err
 	}
 
-	if err := os.WriteFile(db.path, data, 0644); err != nil {
+	if err := db.storage.write(data); err != nil {
 		return err
 	}
+	db.dirty = false
 
 	return nil
 }
 
-func (db *DB) DeleteOldDBFileIfExists(filepath string) error {
+func (db *DB) flushLoop(interval time.Duration) {
+	defer close(db.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			db.mux.RLock()
+			err := db.flush()
+			db.mux.RUnlock()
+			if err != nil {
+				log.Printf("database: flush failed: %v", err)
+			}
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+// Snapshot returns a consistent copy of the whole database.
+func (db *DB) Snapshot() (DBStructure, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return DBStructure{
+		Version:              db.data.Version,
+		Chirps:               maps.Clone(db.data.Chirps),
+		Users:                maps.Clone(db.data.Users),
+		InvalidRefreshTokens: maps.Clone(db.data.InvalidRefreshTokens),
+		Sequences:            maps.Clone(db.data.Sequences),
+		Revisions:            maps.Clone(db.data.Revisions),
+		Likes:                maps.Clone(db.data.Likes),
+		Follows:              maps.Clone(db.data.Follows),
+		Notifications:        maps.Clone(db.data.Notifications),
+		Drafts:               maps.Clone(db.data.Drafts),
+		Bookmarks:            maps.Clone(db.data.Bookmarks),
+	}, nil
+}
+
+// Restore replaces the whole content of the database with dbContent, which
+// must be at the current schema version.
+func (db *DB) Restore(dbContent DBStructure) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if dbContent.Version != SchemaVersion() {
+		return schemaVersionError(dbContent.Version)
+	}
+
+	db.data = dbContent
+	db.indexes = buildIndexes(dbContent)
+	db.pending = nil
+
+	return db.writeDB()
+}
+
+func (db *DB) Close() error {
+	if db.stop != nil {
+		close(db.stop)
+		<-db.done
+		db.stop = nil
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return db.flush()
+}
+
+// LoadFixture reads a database.json style file, upgrading it to the current
+// schema version in memory.
+func LoadFixture(path string) (DBStructure, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	return ParseSnapshot(data)
+}
+
+// ParseSnapshot decodes a serialized DBStructure of any schema version,
+// upgrading it to the current one in memory.
+func ParseSnapshot(data []byte) (DBStructure, error) {
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	if _, err := migrateDocument(doc); err != nil {
+		return DBStructure{}, err
+	}
+
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	return decodeDBStructure(data)
+}
+
+func DeleteOldDBFileIfExists(filepath string) error {
 	if _, err := os.Stat(filepath); err == nil {
 		if err := os.Remove(filepath); err != nil {
 			return nil
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const benchChirps = 1000
+
+// BenchmarkGetChirps lists every chirp from parallel readers while one writer
+// keeps posting and deleting a chirp, as a busy server would.
+func BenchmarkGetChirps(b *testing.B) {
+	for _, bc := range []struct {
+		name       string
+		durability Durability
+	}{
+		{"sync", Durability{SyncEveryWrite: true, FlushInterval: time.Second}},
+		{"batched", Durability{FlushInterval: time.Second}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			db, err := NewDB(filepath.Join(b.TempDir(), "database.json"), bc.durability)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+
+			for i := 0; i < benchChirps; i++ {
+				if _, err := db.CreateChirp(models.Chirp{Body: "chirp " + strconv.Itoa(i), AuthorID: 1 + i%10}); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					chirp, err := db.CreateChirp(models.Chirp{Body: "churn", AuthorID: 1})
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					if err := db.DeleteChirp(strconv.Itoa(chirp.ID)); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					page, err := db.ListChirps(ChirpQuery{SortOrder: "asc"})
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					if len(page.Chirps) < benchChirps {
+						b.Errorf("listed %d chirps, want at least %d", len(page.Chirps), benchChirps)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var ErrDraftNotFound = errors.New("draft not found")
+
+// PublishDraft posts a draft as a new chirp and removes the draft. A reply or
+// quote whose chirp has been deleted since is posted as a plain chirp.
+func PublishDraft(tx Tx, id int) (models.Chirp, error) {
+	draft, err := tx.GetDraft(id)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	chirp := models.Chirp{Kind: models.ChirpKindChirp, Body: draft.Body, AuthorID: draft.AuthorID, Media: draft.Media}
+	if parent, err := tx.GetChirp(strconv.Itoa(draft.InReplyTo)); draft.InReplyTo != 0 && err == nil && !parent.Deleted {
+		chirp.InReplyTo = parent.ID
+	}
+	if original, err := tx.GetChirp(strconv.Itoa(draft.OriginalID)); draft.OriginalID != 0 && err == nil && !original.Deleted {
+		chirp.Kind = models.ChirpKindQuote
+		chirp.OriginalID = original.ID
+	}
+
+	newChirp, err := tx.CreateChirp(chirp)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := tx.DeleteDraft(id); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return newChirp, nil
+}
+
+func (db *DB) CreateDraft(draft models.Draft) (models.Draft, error) {
+	var newDraft models.Draft
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		newDraft, err = tx.CreateDraft(draft)
+		return err
+	})
+
+	return newDraft, err
+}
+
+func (tx *dbTx) CreateDraft(draft models.Draft) (models.Draft, error) {
+	id, err := tx.nextID("drafts")
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	now := time.Now().UTC()
+	draft.ID = id
+	draft.CreatedAt = now
+	draft.UpdatedAt = now
+
+	if err := tx.put("drafts", strconv.Itoa(id), draft); err != nil {
+		return models.Draft{}, err
+	}
+
+	return draft, nil
+}
+
+func (db *DB) GetDraft(id int) (models.Draft, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getDraft(id)
+}
+
+func (tx *dbTx) GetDraft(id int) (models.Draft, error) {
+	return tx.db.getDraft(id)
+}
+
+func (db *DB) getDraft(id int) (models.Draft, error) {
+	draft, ok := db.data.Drafts[id]
+	if !ok {
+		return models.Draft{}, ErrDraftNotFound
+	}
+
+	return draft, nil
+}
+
+func (db *DB) GetDrafts(authorID int) ([]models.Draft, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getDrafts(authorID), nil
+}
+
+func (tx *dbTx) GetDrafts(authorID int) ([]models.Draft, error) {
+	return tx.db.getDrafts(authorID), nil
+}
+
+func (db *DB) getDrafts(authorID int) []models.Draft {
+	drafts := make([]models.Draft, 0, len(db.indexes.drafts[authorID]))
+	for id := range db.indexes.drafts[authorID] {
+		drafts = append(drafts, db.data.Drafts[id])
+	}
+
+	sort.Slice(drafts, func(i, j int) bool {
+		return drafts[i].ID > drafts[j].ID
+	})
+
+	return drafts
+}
+
+func (db *DB) GetDueDrafts(now time.Time) ([]models.Draft, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	var drafts []models.Draft
+	for _, draft := range db.data.Drafts {
+		if draft.PublishAt != nil && !draft.PublishAt.After(now) {
+			drafts = append(drafts, draft)
+		}
+	}
+
+	sortDueDrafts(drafts)
+	return drafts, nil
+}
+
+func sortDueDrafts(drafts []models.Draft) {
+	sort.Slice(drafts, func(i, j int) bool {
+		if !drafts[i].PublishAt.Equal(*drafts[j].PublishAt) {
+			return drafts[i].PublishAt.Before(*drafts[j].PublishAt)
+		}
+		return drafts[i].ID < drafts[j].ID
+	})
+}
+
+func (db *DB) UpdateDraft(draft models.Draft) (models.Draft, error) {
+	var updated models.Draft
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		updated, err = tx.UpdateDraft(draft)
+		return err
+	})
+
+	return updated, err
+}
+
+func (tx *dbTx) UpdateDraft(draft models.Draft) (models.Draft, error) {
+	current, err := tx.db.getDraft(draft.ID)
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	current.Body = draft.Body
+	current.PublishAt = draft.PublishAt
+	current.UpdatedAt = time.Now().UTC()
+
+	if err := tx.put("drafts", strconv.Itoa(current.ID), current); err != nil {
+		return models.Draft{}, err
+	}
+
+	return current, nil
+}
+
+func (db *DB) DeleteDraft(id int) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.DeleteDraft(id)
+	})
+}
+
+func (tx *dbTx) DeleteDraft(id int) error {
+	if _, err := tx.db.getDraft(id); err != nil {
+		return err
+	}
+
+	return tx.delete("drafts", strconv.Itoa(id))
+}
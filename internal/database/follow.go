@@ -0,0 +1,147 @@
+package database
+
+import (
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"sort"
+	"time"
+)
+
+func followKey(followerID, followeeID int) string {
+	return fmt.Sprintf("%d:%d", followerID, followeeID)
+}
+
+func (db *DB) Follow(followerID, followeeID int) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.Follow(followerID, followeeID)
+	})
+}
+
+func (tx *dbTx) Follow(followerID, followeeID int) error {
+	key := followKey(followerID, followeeID)
+	if _, ok := tx.db.data.Follows[key]; ok {
+		return nil
+	}
+
+	if err := tx.put("follows", key, models.Follow{FollowerID: followerID, FolloweeID: followeeID, CreatedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+
+	return tx.notify(models.Notification{UserID: followeeID, Kind: models.NotificationFollow, ActorID: followerID})
+}
+
+func (db *DB) Unfollow(followerID, followeeID int) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.Unfollow(followerID, followeeID)
+	})
+}
+
+func (tx *dbTx) Unfollow(followerID, followeeID int) error {
+	key := followKey(followerID, followeeID)
+	if _, ok := tx.db.data.Follows[key]; !ok {
+		return nil
+	}
+
+	if err := tx.delete("follows", key); err != nil {
+		return err
+	}
+
+	return tx.unnotify(models.NotificationFollow, followeeID, followerID, 0)
+}
+
+// deleteFollows drops every follow relation of a user that is being deleted.
+func (tx *dbTx) deleteFollows(userID int) error {
+	var keys []string
+	for followeeID := range tx.db.indexes.following[userID] {
+		keys = append(keys, followKey(userID, followeeID))
+	}
+	for followerID := range tx.db.indexes.followers[userID] {
+		keys = append(keys, followKey(followerID, userID))
+	}
+
+	for _, key := range keys {
+		if err := tx.delete("follows", key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetFollowers(userID int) ([]models.Follow, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getFollowers(userID), nil
+}
+
+func (tx *dbTx) GetFollowers(userID int) ([]models.Follow, error) {
+	return tx.db.getFollowers(userID), nil
+}
+
+func (db *DB) getFollowers(userID int) []models.Follow {
+	follows := make([]models.Follow, 0, len(db.indexes.followers[userID]))
+	for followerID := range db.indexes.followers[userID] {
+		follows = append(follows, db.data.Follows[followKey(followerID, userID)])
+	}
+
+	return sortFollows(follows)
+}
+
+func (db *DB) GetFollowing(userID int) ([]models.Follow, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getFollowing(userID), nil
+}
+
+func (tx *dbTx) GetFollowing(userID int) ([]models.Follow, error) {
+	return tx.db.getFollowing(userID), nil
+}
+
+func (db *DB) getFollowing(userID int) []models.Follow {
+	follows := make([]models.Follow, 0, len(db.indexes.following[userID]))
+	for followeeID := range db.indexes.following[userID] {
+		follows = append(follows, db.data.Follows[followKey(userID, followeeID)])
+	}
+
+	return sortFollows(follows)
+}
+
+func (db *DB) CountFollowers(userID int) (int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return len(db.indexes.followers[userID]), nil
+}
+
+func (tx *dbTx) CountFollowers(userID int) (int, error) {
+	return len(tx.db.indexes.followers[userID]), nil
+}
+
+func (db *DB) CountFollowing(userID int) (int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return len(db.indexes.following[userID]), nil
+}
+
+func (tx *dbTx) CountFollowing(userID int) (int, error) {
+	return len(tx.db.indexes.following[userID]), nil
+}
+
+// sortFollows orders follows newest first.
+func sortFollows(follows []models.Follow) []models.Follow {
+	sort.Slice(follows, func(i, j int) bool {
+		a, b := follows[i], follows[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		if a.FollowerID != b.FollowerID {
+			return a.FollowerID > b.FollowerID
+		}
+		return a.FolloweeID > b.FolloweeID
+	})
+
+	return follows
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const (
+	benchFollowees         = 5000
+	benchChirpsPerFollowee = 4
+	benchReaderID          = 1
+)
+
+// timelineFixture has one reader following benchFollowees users, each of
+// whom has posted benchChirpsPerFollowee chirps.
+func timelineFixture() DBStructure {
+	dbContent := newDBStructure()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for userID := benchReaderID; userID <= benchReaderID+benchFollowees; userID++ {
+		dbContent.Users[userID] = models.User{ID: userID, Email: "user" + strconv.Itoa(userID) + "@example.com", Password: []byte("hash")}
+		if userID == benchReaderID {
+			continue
+		}
+
+		dbContent.Follows[followKey(benchReaderID, userID)] = models.Follow{FollowerID: benchReaderID, FolloweeID: userID, CreatedAt: start}
+		for i := 0; i < benchChirpsPerFollowee; i++ {
+			id := len(dbContent.Chirps) + 1
+			createdAt := start.Add(time.Duration(id) * time.Second)
+			dbContent.Chirps[id] = models.Chirp{ID: id, Kind: models.ChirpKindChirp, Body: "chirp " + strconv.Itoa(id), AuthorID: userID, CreatedAt: createdAt, UpdatedAt: createdAt}
+		}
+	}
+	dbContent.Sequences["users"] = benchReaderID + benchFollowees
+	dbContent.Sequences["chirps"] = len(dbContent.Chirps)
+
+	return dbContent
+}
+
+// BenchmarkTimeline reads the home timeline of a user following
+// benchFollowees others, which is assembled from their chirps on every
+// request.
+func BenchmarkTimeline(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		open func(dir string) (Store, error)
+	}{
+		{"json", func(dir string) (Store, error) {
+			return NewDB(filepath.Join(dir, "database.json"), Durability{FlushInterval: time.Minute})
+		}},
+		{"sqlite", func(dir string) (Store, error) {
+			return NewSQLiteDB(filepath.Join(dir, "database.db"))
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			store, err := bc.open(b.TempDir())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer store.Close()
+
+			if err := store.Restore(timelineFixture()); err != nil {
+				b.Fatal(err)
+			}
+
+			query := ChirpQuery{TimelineOf: benchReaderID, SortOrder: "desc", Limit: DefaultPageLimit}
+
+			b.Run("first page", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					page, err := store.ListChirps(query)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(page.Chirps) != DefaultPageLimit {
+						b.Fatalf("got %d chirps, want %d", len(page.Chirps), DefaultPageLimit)
+					}
+				}
+			})
+
+			// Halfway down the timeline, reached by following NextCursor.
+			deep := query
+			for i := 0; i < benchFollowees*benchChirpsPerFollowee/2/DefaultPageLimit; i++ {
+				page, err := store.ListChirps(deep)
+				if err != nil {
+					b.Fatal(err)
+				}
+				deep.Cursor = page.NextCursor
+			}
+
+			b.Run("deep page", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					page, err := store.ListChirps(deep)
+					if err != nil {
+						b.Fatal(err)
+					}
+					if len(page.Chirps) != DefaultPageLimit {
+						b.Fatalf("got %d chirps, want %d", len(page.Chirps), DefaultPageLimit)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"strconv"
+	"strings"
+)
+
+// indexes are lookups over the resident DBStructure that are never persisted.
+// They are rebuilt whenever the data is replaced and kept up to date by
+// commit.
+type indexes struct {
+	userByEmail    map[string]int
+	userByHandle   map[string]int
+	chirpsByAuthor map[int]map[int]struct{}
+	// replies maps a chirp ID to the IDs of the chirps replying to it.
+	replies map[int]map[int]struct{}
+	// likesByChirp maps a chirp ID to the IDs of the users who like it, and
+	// likesByUser the other way round.
+	likesByChirp map[int]map[int]struct{}
+	likesByUser  map[int]map[int]struct{}
+	// rechirps maps a chirp ID to the IDs of its rechirps.
+	rechirps map[int]map[int]struct{}
+	// following maps a user ID to the users they follow, and followers the
+	// other way round.
+	following map[int]map[int]struct{}
+	followers map[int]map[int]struct{}
+	// tagged maps a hashtag to the IDs of the chirps using it.
+	tagged map[string]map[int]struct{}
+	// searchTerms maps the words in chirp bodies to the IDs of the chirps
+	// using them.
+	searchTerms *termIndex
+	// notifications maps a user ID to the IDs of their notifications, and
+	// the other two map a chirp or acting user to the notifications about
+	// them.
+	notifications        map[int]map[int]struct{}
+	notificationsByChirp map[int]map[int]struct{}
+	notificationsByActor map[int]map[int]struct{}
+	// drafts maps a user ID to the IDs of their drafts.
+	drafts map[int]map[int]struct{}
+	// bookmarksByChirp maps a chirp ID to the IDs of the users who bookmarked
+	// it, and bookmarksByUser the other way round.
+	bookmarksByChirp map[int]map[int]struct{}
+	bookmarksByUser  map[int]map[int]struct{}
+}
+
+func buildIndexes(dbContent DBStructure) indexes {
+	ix := indexes{
+		userByEmail:    make(map[string]int),
+		userByHandle:   make(map[string]int),
+		chirpsByAuthor: make(map[int]map[int]struct{}),
+		replies:        make(map[int]map[int]struct{}),
+		likesByChirp:   make(map[int]map[int]struct{}),
+		likesByUser:    make(map[int]map[int]struct{}),
+		rechirps:       make(map[int]map[int]struct{}),
+		following:      make(map[int]map[int]struct{}),
+		followers:      make(map[int]map[int]struct{}),
+		tagged:         make(map[string]map[int]struct{}),
+		searchTerms:    newTermIndex(),
+
+		notifications:        make(map[int]map[int]struct{}),
+		notificationsByChirp: make(map[int]map[int]struct{}),
+		notificationsByActor: make(map[int]map[int]struct{}),
+		drafts:               make(map[int]map[int]struct{}),
+		bookmarksByChirp:     make(map[int]map[int]struct{}),
+		bookmarksByUser:      make(map[int]map[int]struct{}),
+	}
+
+	for _, user := range dbContent.Users {
+		ix.addUser(user)
+	}
+
+	for _, chirp := range dbContent.Chirps {
+		ix.addChirp(chirp)
+	}
+
+	for _, like := range dbContent.Likes {
+		ix.addLike(like)
+	}
+
+	for _, follow := range dbContent.Follows {
+		ix.addFollow(follow)
+	}
+
+	for _, notification := range dbContent.Notifications {
+		ix.addNotification(notification)
+	}
+
+	for _, draft := range dbContent.Drafts {
+		addToSet(ix.drafts, draft.AuthorID, draft.ID)
+	}
+
+	for _, bookmark := range dbContent.Bookmarks {
+		ix.addBookmark(bookmark)
+	}
+
+	return ix
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(email)
+}
+
+func handleKey(handle string) string {
+	return strings.ToLower(handle)
+}
+
+func (ix indexes) addUser(user models.User) {
+	ix.userByEmail[emailKey(user.Email)] = user.ID
+	if user.Handle != "" {
+		ix.userByHandle[handleKey(user.Handle)] = user.ID
+	}
+}
+
+func (ix indexes) removeUser(user models.User) {
+	if ix.userByEmail[emailKey(user.Email)] == user.ID {
+		delete(ix.userByEmail, emailKey(user.Email))
+	}
+	if user.Handle != "" && ix.userByHandle[handleKey(user.Handle)] == user.ID {
+		delete(ix.userByHandle, handleKey(user.Handle))
+	}
+}
+
+// Tombstones have no author, so they only take part in the reply index.
+func (ix indexes) addChirp(chirp models.Chirp) {
+	if !chirp.Deleted {
+		addToSet(ix.chirpsByAuthor, chirp.AuthorID, chirp.ID)
+		for _, tag := range chirp.Tags {
+			addToSet(ix.tagged, tag, chirp.ID)
+		}
+		ix.searchTerms.add(chirp.ID, chirp.Body)
+	}
+	if chirp.InReplyTo != 0 {
+		addToSet(ix.replies, chirp.InReplyTo, chirp.ID)
+	}
+	if chirp.Kind == models.ChirpKindRechirp {
+		addToSet(ix.rechirps, chirp.OriginalID, chirp.ID)
+	}
+}
+
+func (ix indexes) removeChirp(chirp models.Chirp) {
+	removeFromSet(ix.chirpsByAuthor, chirp.AuthorID, chirp.ID)
+	removeFromSet(ix.replies, chirp.InReplyTo, chirp.ID)
+	removeFromSet(ix.rechirps, chirp.OriginalID, chirp.ID)
+	for _, tag := range chirp.Tags {
+		removeFromSet(ix.tagged, tag, chirp.ID)
+	}
+	ix.searchTerms.remove(chirp.ID, chirp.Body)
+}
+
+func (ix indexes) addLike(like models.Like) {
+	addToSet(ix.likesByChirp, like.ChirpID, like.UserID)
+	addToSet(ix.likesByUser, like.UserID, like.ChirpID)
+}
+
+func (ix indexes) removeLike(like models.Like) {
+	removeFromSet(ix.likesByChirp, like.ChirpID, like.UserID)
+	removeFromSet(ix.likesByUser, like.UserID, like.ChirpID)
+}
+
+func (ix indexes) addFollow(follow models.Follow) {
+	addToSet(ix.following, follow.FollowerID, follow.FolloweeID)
+	addToSet(ix.followers, follow.FolloweeID, follow.FollowerID)
+}
+
+func (ix indexes) removeFollow(follow models.Follow) {
+	removeFromSet(ix.following, follow.FollowerID, follow.FolloweeID)
+	removeFromSet(ix.followers, follow.FolloweeID, follow.FollowerID)
+}
+
+func (ix indexes) addBookmark(bookmark models.Bookmark) {
+	addToSet(ix.bookmarksByChirp, bookmark.ChirpID, bookmark.UserID)
+	addToSet(ix.bookmarksByUser, bookmark.UserID, bookmark.ChirpID)
+}
+
+func (ix indexes) removeBookmark(bookmark models.Bookmark) {
+	removeFromSet(ix.bookmarksByChirp, bookmark.ChirpID, bookmark.UserID)
+	removeFromSet(ix.bookmarksByUser, bookmark.UserID, bookmark.ChirpID)
+}
+
+func (ix indexes) addNotification(notification models.Notification) {
+	addToSet(ix.notifications, notification.UserID, notification.ID)
+	addToSet(ix.notificationsByChirp, notification.ChirpID, notification.ID)
+	addToSet(ix.notificationsByActor, notification.ActorID, notification.ID)
+}
+
+func (ix indexes) removeNotification(notification models.Notification) {
+	removeFromSet(ix.notifications, notification.UserID, notification.ID)
+	removeFromSet(ix.notificationsByChirp, notification.ChirpID, notification.ID)
+	removeFromSet(ix.notificationsByActor, notification.ActorID, notification.ID)
+}
+
+func addToSet[K comparable](sets map[K]map[int]struct{}, key K, id int) {
+	ids, ok := sets[key]
+	if !ok {
+		ids = make(map[int]struct{})
+		sets[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func removeFromSet[K comparable](sets map[K]map[int]struct{}, key K, id int) {
+	ids, ok := sets[key]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(sets, key)
+	}
+}
+
+// keyID parses a record key as an ID. Keys that are not IDs give 0, which no
+// record uses.
+func keyID(key string) int {
+	id, _ := strconv.Atoi(key)
+	return id
+}
+
+// unindex drops the record entry is about to overwrite or delete.
+func (db *DB) unindex(entry walEntry) {
+	switch entry.Table {
+	case "users":
+		if user, ok := db.data.Users[keyID(entry.Key)]; ok {
+			db.indexes.removeUser(user)
+		}
+	case "chirps":
+		if chirp, ok := db.data.Chirps[keyID(entry.Key)]; ok {
+			db.indexes.removeChirp(chirp)
+		}
+	case "likes":
+		if like, ok := db.data.Likes[entry.Key]; ok {
+			db.indexes.removeLike(like)
+		}
+	case "follows":
+		if follow, ok := db.data.Follows[entry.Key]; ok {
+			db.indexes.removeFollow(follow)
+		}
+	case "notifications":
+		if notification, ok := db.data.Notifications[keyID(entry.Key)]; ok {
+			db.indexes.removeNotification(notification)
+		}
+	case "drafts":
+		if draft, ok := db.data.Drafts[keyID(entry.Key)]; ok {
+			removeFromSet(db.indexes.drafts, draft.AuthorID, draft.ID)
+		}
+	case "bookmarks":
+		if bookmark, ok := db.data.Bookmarks[entry.Key]; ok {
+			db.indexes.removeBookmark(bookmark)
+		}
+	}
+}
+
+// index adds the record stored under entry's key, if there is one.
+func (db *DB) index(entry walEntry) {
+	switch entry.Table {
+	case "users":
+		if user, ok := db.data.Users[keyID(entry.Key)]; ok {
+			db.indexes.addUser(user)
+		}
+	case "chirps":
+		if chirp, ok := db.data.Chirps[keyID(entry.Key)]; ok {
+			db.indexes.addChirp(chirp)
+		}
+	case "likes":
+		if like, ok := db.data.Likes[entry.Key]; ok {
+			db.indexes.addLike(like)
+		}
+	case "follows":
+		if follow, ok := db.data.Follows[entry.Key]; ok {
+			db.indexes.addFollow(follow)
+		}
+	case "notifications":
+		if notification, ok := db.data.Notifications[keyID(entry.Key)]; ok {
+			db.indexes.addNotification(notification)
+		}
+	case "drafts":
+		if draft, ok := db.data.Drafts[keyID(entry.Key)]; ok {
+			addToSet(db.indexes.drafts, draft.AuthorID, draft.ID)
+		}
+	case "bookmarks":
+		if bookmark, ok := db.data.Bookmarks[entry.Key]; ok {
+			db.indexes.addBookmark(bookmark)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func likeKey(chirpID, userID int) string {
+	return fmt.Sprintf("%d:%d", chirpID, userID)
+}
+
+func (db *DB) LikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.LikeChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) LikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := tx.db.getChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	key := likeKey(chirp.ID, userID)
+	if _, ok := tx.db.data.Likes[key]; ok {
+		return chirp, nil
+	}
+
+	if err := tx.put("likes", key, models.Like{ChirpID: chirp.ID, UserID: userID, CreatedAt: time.Now().UTC()}); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.AuthorID != userID {
+		if err := tx.notify(models.Notification{UserID: chirp.AuthorID, Kind: models.NotificationLike, ActorID: userID, ChirpID: chirp.ID}); err != nil {
+			return models.Chirp{}, err
+		}
+	}
+
+	chirp.LikeCount++
+	if err := tx.put("chirps", strconv.Itoa(chirp.ID), chirp); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (db *DB) UnlikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnlikeChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) UnlikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := tx.db.getChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	key := likeKey(chirp.ID, userID)
+	if _, ok := tx.db.data.Likes[key]; !ok {
+		return chirp, nil
+	}
+
+	if err := tx.delete("likes", key); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := tx.unnotify(models.NotificationLike, chirp.AuthorID, userID, chirp.ID); err != nil {
+		return models.Chirp{}, err
+	}
+
+	chirp.LikeCount--
+	if err := tx.put("chirps", strconv.Itoa(chirp.ID), chirp); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// deleteLikes drops every like of a chirp that is being deleted.
+func (tx *dbTx) deleteLikes(chirpID int) error {
+	userIDs := make([]int, 0, len(tx.db.indexes.likesByChirp[chirpID]))
+	for userID := range tx.db.indexes.likesByChirp[chirpID] {
+		userIDs = append(userIDs, userID)
+	}
+
+	for _, userID := range userIDs {
+		if err := tx.delete("likes", likeKey(chirpID, userID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) GetLikedChirps(userID int) ([]models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getLikedChirps(userID)
+}
+
+func (tx *dbTx) GetLikedChirps(userID int) ([]models.Chirp, error) {
+	return tx.db.getLikedChirps(userID)
+}
+
+func (db *DB) getLikedChirps(userID int) ([]models.Chirp, error) {
+	likes := make([]models.Like, 0, len(db.indexes.likesByUser[userID]))
+	for chirpID := range db.indexes.likesByUser[userID] {
+		likes = append(likes, db.data.Likes[likeKey(chirpID, userID)])
+	}
+
+	sort.Slice(likes, func(i, j int) bool {
+		if !likes[i].CreatedAt.Equal(likes[j].CreatedAt) {
+			return likes[i].CreatedAt.After(likes[j].CreatedAt)
+		}
+		return likes[i].ChirpID > likes[j].ChirpID
+	})
+
+	chirps := make([]models.Chirp, 0, len(likes))
+	for _, like := range likes {
+		chirps = append(chirps, db.data.Chirps[like.ChirpID])
+	}
+
+	return chirps, nil
+}
+
+func (db *DB) LikedChirpIDs(userID int, chirpIDs []int) (map[int]bool, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.likedChirpIDs(userID, chirpIDs), nil
+}
+
+func (tx *dbTx) LikedChirpIDs(userID int, chirpIDs []int) (map[int]bool, error) {
+	return tx.db.likedChirpIDs(userID, chirpIDs), nil
+}
+
+func (db *DB) likedChirpIDs(userID int, chirpIDs []int) map[int]bool {
+	liked := make(map[int]bool)
+	for _, chirpID := range chirpIDs {
+		if _, ok := db.indexes.likesByUser[userID][chirpID]; ok {
+			liked[chirpID] = true
+		}
+	}
+
+	return liked
+}
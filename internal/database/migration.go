@@ -0,0 +1,567 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"golang.org/x/text/cases"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Migration upgrades stored data from Version-1 to Version. Each backend
+// provides its own step; a nil step means the backend needs no change.
+type Migration struct {
+	Version     int
+	Description string
+	JSON        func(doc map[string]any) error
+	SQLite      func(tx *sql.Tx) error
+}
+
+// migrations must stay ordered by Version, starting at 1. Version 0 is the
+// unversioned layout written before migrations existed.
+var migrations = []Migration{
+	{Version: 1, Description: "track ID sequences per table", JSON: addSequences},
+	{Version: 2, Description: "index chirps by author and users by case-insensitive email", SQLite: execSQL(`
+		CREATE INDEX IF NOT EXISTS idx_chirps_author_id ON chirps (author_id);
+		CREATE INDEX IF NOT EXISTS idx_users_email_nocase ON users (email COLLATE NOCASE);
+	`)},
+	{Version: 3, Description: "add created_at and updated_at to chirps", JSON: addChirpTimestamps, SQLite: addChirpTimestampColumns},
+	{Version: 4, Description: "keep revisions of edited chirps", SQLite: execSQL(`
+		ALTER TABLE chirps ADD COLUMN edited INTEGER NOT NULL DEFAULT 0;
+		CREATE TABLE IF NOT EXISTS chirp_revisions (
+			chirp_id   INTEGER  NOT NULL,
+			revision   INTEGER  NOT NULL,
+			body       TEXT     NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (chirp_id, revision)
+		);
+	`)},
+	{Version: 5, Description: "thread chirps as replies and tombstone deleted parents", SQLite: execSQL(`
+		ALTER TABLE chirps ADD COLUMN in_reply_to INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE chirps ADD COLUMN deleted INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS idx_chirps_in_reply_to ON chirps (in_reply_to);
+	`)},
+	{Version: 6, Description: "add likes and a like count to chirps", SQLite: execSQL(`
+		ALTER TABLE chirps ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+		CREATE TABLE IF NOT EXISTS likes (
+			chirp_id   INTEGER  NOT NULL,
+			user_id    INTEGER  NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (chirp_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_likes_user_id ON likes (user_id, created_at);
+	`)},
+	{Version: 7, Description: "add rechirp and quote chirp kinds", JSON: addChirpKinds, SQLite: execSQL(`
+		ALTER TABLE chirps ADD COLUMN kind TEXT NOT NULL DEFAULT 'chirp';
+		ALTER TABLE chirps ADD COLUMN original_id INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX IF NOT EXISTS idx_chirps_original_id ON chirps (original_id);
+	`)},
+	{Version: 8, Description: "add the follow graph", SQLite: execSQL(`
+		CREATE TABLE IF NOT EXISTS follows (
+			follower_id INTEGER  NOT NULL,
+			followee_id INTEGER  NOT NULL,
+			created_at  DATETIME NOT NULL,
+			PRIMARY KEY (follower_id, followee_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_follows_followee_id ON follows (followee_id);
+	`)},
+	{Version: 9, Description: "index hashtags in chirps", JSON: addChirpTags, SQLite: addChirpTagTable},
+	{Version: 10, Description: "add notifications and the handles mentions resolve to", SQLite: execSQL(`
+		ALTER TABLE users ADD COLUMN handle TEXT NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS notifications (
+			id         INTEGER  PRIMARY KEY AUTOINCREMENT,
+			user_id    INTEGER  NOT NULL,
+			kind       TEXT     NOT NULL,
+			actor_id   INTEGER  NOT NULL,
+			chirp_id   INTEGER  NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			read       INTEGER  NOT NULL DEFAULT 0
+		);
+		CREATE INDEX IF NOT EXISTS idx_notifications_user_id ON notifications (user_id, id);
+		CREATE INDEX IF NOT EXISTS idx_notifications_actor_id ON notifications (actor_id);
+		CREATE INDEX IF NOT EXISTS idx_notifications_chirp_id ON notifications (chirp_id);
+	`)},
+	{Version: 11, Description: "make handles unique and add public profile fields to users", SQLite: execSQL(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_users_handle_nocase ON users (handle COLLATE NOCASE) WHERE handle <> '';
+		ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+		ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+	`)},
+	{Version: 12, Description: "attach media to chirps", SQLite: execSQL(`
+		ALTER TABLE chirps ADD COLUMN media TEXT NOT NULL DEFAULT '';
+	`)},
+	{Version: 13, Description: "index chirp words for search", SQLite: addChirpTermTable},
+	{Version: 14, Description: "add drafts and scheduled chirps", SQLite: execSQL(`
+		CREATE TABLE IF NOT EXISTS drafts (
+			id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+			body        TEXT     NOT NULL,
+			author_id   INTEGER  NOT NULL,
+			in_reply_to INTEGER  NOT NULL DEFAULT 0,
+			original_id INTEGER  NOT NULL DEFAULT 0,
+			media       TEXT     NOT NULL DEFAULT '',
+			publish_at  DATETIME,
+			created_at  DATETIME NOT NULL,
+			updated_at  DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_drafts_author_id ON drafts (author_id);
+		CREATE INDEX IF NOT EXISTS idx_drafts_publish_at ON drafts (publish_at) WHERE publish_at IS NOT NULL;
+	`)},
+	{Version: 15, Description: "add bookmarks and pinned chirps", SQLite: execSQL(`
+		CREATE TABLE IF NOT EXISTS bookmarks (
+			chirp_id   INTEGER  NOT NULL,
+			user_id    INTEGER  NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (chirp_id, user_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_bookmarks_user_id ON bookmarks (user_id);
+		ALTER TABLE chirps ADD COLUMN pinned_at DATETIME;
+		CREATE INDEX IF NOT EXISTS idx_chirps_pinned ON chirps (author_id) WHERE pinned_at IS NOT NULL;
+	`)},
+}
+
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func pendingMigrations(version int) []Migration {
+	for i, m := range migrations {
+		if m.Version > version {
+			return migrations[i:]
+		}
+	}
+
+	return nil
+}
+
+func schemaVersionError(version int) error {
+	if version > SchemaVersion() {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", version, SchemaVersion())
+	}
+
+	return fmt.Errorf("database schema is at version %d, expected %d: run `dolores migrate`", version, SchemaVersion())
+}
+
+func decodeDocument(data []byte) (map[string]any, error) {
+	doc := make(map[string]any)
+	if len(data) == 0 {
+		return doc, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func documentVersion(doc map[string]any) int {
+	return intValue(doc["version"])
+}
+
+// migrateDocument runs all pending JSON migrations on doc.
+func migrateDocument(doc map[string]any) ([]Migration, error) {
+	version := documentVersion(doc)
+	if version > SchemaVersion() {
+		return nil, schemaVersionError(version)
+	}
+
+	pending := pendingMigrations(version)
+	for _, m := range pending {
+		if m.JSON != nil {
+			if err := m.JSON(doc); err != nil {
+				return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+			}
+		}
+		doc["version"] = m.Version
+	}
+
+	return pending, nil
+}
+
+// applyToDocument replays a log entry on an undecoded document, so logs
+// written by an older version can be folded in before migrating.
+func applyToDocument(doc map[string]any, entry walEntry) error {
+	table := documentTable(doc, entry.Table)
+	switch entry.Op {
+	case walPut:
+		value, err := decodeValue(entry.Value)
+		if err != nil {
+			return err
+		}
+		table[entry.Key] = value
+	case walDelete:
+		delete(table, entry.Key)
+	default:
+		return fmt.Errorf("wal: unknown op %q", entry.Op)
+	}
+
+	return nil
+}
+
+func decodeValue(data []byte) (any, error) {
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func documentTable(doc map[string]any, name string) map[string]any {
+	table, ok := doc[name].(map[string]any)
+	if !ok {
+		table = make(map[string]any)
+		doc[name] = table
+	}
+
+	return table
+}
+
+func intValue(v any) int {
+	switch n := v.(type) {
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case float64:
+		return int(n)
+	case int:
+		return n
+	default:
+		return 0
+	}
+}
+
+// MigrateJSON upgrades the JSON store at path in place, folding in any
+// pending operation log first. With dryRun nothing is written.
+func MigrateJSON(path string, dryRun bool) (from int, applied []Migration, err error) {
+	fs := newFileStorage(path)
+	data, entries, err := fs.read()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	doc, err := decodeDocument(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(data) == 0 {
+		doc["version"] = SchemaVersion()
+	}
+	from = documentVersion(doc)
+
+	for _, entry := range entries {
+		if err := applyToDocument(doc, entry); err != nil {
+			return from, nil, err
+		}
+	}
+
+	applied, err = migrateDocument(doc)
+	if err != nil || dryRun || (len(applied) == 0 && len(entries) == 0) {
+		return from, applied, err
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return from, nil, err
+	}
+
+	return from, applied, fs.write(out)
+}
+
+// MigrateSQLite upgrades the SQLite store at path in place. With dryRun the
+// migrations run inside a transaction that is rolled back.
+func MigrateSQLite(path string, dryRun bool) (from int, applied []Migration, err error) {
+	var db *sql.DB
+	if dryRun {
+		// A missing file would be created at the current version.
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return SchemaVersion(), nil, nil
+		}
+		// openSQLite would switch the file to WAL mode, so a dry run opens
+		// the file as it is.
+		db, err = sql.Open("sqlite", "file:"+path+"?mode=rw&_pragma=busy_timeout(5000)")
+	} else {
+		db, err = openSQLite(path)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	defer db.Close()
+
+	from, err = sqliteUserVersion(db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	applied, err = migrateSQLite(db, from, dryRun)
+
+	return from, applied, err
+}
+
+func migrateSQLite(db *sql.DB, version int, dryRun bool) ([]Migration, error) {
+	if version > SchemaVersion() {
+		return nil, schemaVersionError(version)
+	}
+
+	pending := pendingMigrations(version)
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, m := range pending {
+		if m.SQLite != nil {
+			if err := m.SQLite(tx); err != nil {
+				return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(SchemaVersion())); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return pending, nil
+	}
+
+	return pending, tx.Commit()
+}
+
+func sqliteUserVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("PRAGMA user_version").Scan(&version)
+
+	return version, err
+}
+
+// execSQL builds a SQLite migration step from plain statements.
+func execSQL(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// addSequences starts each table's ID sequence after its highest stored ID.
+func addSequences(doc map[string]any) error {
+	sequences := documentTable(doc, "sequences")
+	for _, name := range []string{"chirps", "users"} {
+		last := intValue(sequences[name])
+		for key := range documentTable(doc, name) {
+			id, err := strconv.Atoi(key)
+			if err != nil {
+				return err
+			}
+			if id > last {
+				last = id
+			}
+		}
+		sequences[name] = last
+	}
+
+	return nil
+}
+
+// addChirpTimestamps stamps existing chirps with the migration time, as
+// their real creation time was never recorded.
+func addChirpTimestamps(doc map[string]any) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for key, value := range documentTable(doc, "chirps") {
+		chirp, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("chirp %s is not an object", key)
+		}
+		if _, ok := chirp["created_at"]; !ok {
+			chirp["created_at"] = now
+		}
+		if _, ok := chirp["updated_at"]; !ok {
+			chirp["updated_at"] = chirp["created_at"]
+		}
+	}
+
+	return nil
+}
+
+func addChirpTimestampColumns(tx *sql.Tx) error {
+	now := time.Now().UTC()
+	_, err := tx.Exec(`
+		ALTER TABLE chirps ADD COLUMN created_at DATETIME;
+		ALTER TABLE chirps ADD COLUMN updated_at DATETIME;
+		CREATE INDEX IF NOT EXISTS idx_chirps_created_at ON chirps (created_at, id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE chirps SET created_at = ?, updated_at = ? WHERE created_at IS NULL", now, now)
+
+	return err
+}
+
+// addChirpKinds marks every existing chirp as a plain chirp.
+func addChirpKinds(doc map[string]any) error {
+	for key, value := range documentTable(doc, "chirps") {
+		chirp, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("chirp %s is not an object", key)
+		}
+		if _, ok := chirp["kind"]; !ok {
+			chirp["kind"] = "chirp"
+		}
+	}
+
+	return nil
+}
+
+// hashtagPatternV9 and parseTagsV9 are the hashtag rules as migration 9
+// shipped them. The migration keeps its own copy, so that later changes to
+// parseTags do not change what it does to stores not yet migrated.
+var hashtagPatternV9 = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_&#])#([\p{L}\p{M}\p{N}_]+)`)
+
+func parseTagsV9(body string) []string {
+	var tags []string
+	for _, match := range hashtagPatternV9.FindAllStringSubmatch(body, -1) {
+		tag := cases.Fold().String(match[1])
+		if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// addChirpTags parses the hashtags out of every existing chirp.
+func addChirpTags(doc map[string]any) error {
+	for key, value := range documentTable(doc, "chirps") {
+		chirp, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("chirp %s is not an object", key)
+		}
+		body, _ := chirp["body"].(string)
+		if tags := parseTagsV9(body); len(tags) > 0 {
+			chirp["tags"] = tags
+		}
+	}
+
+	return nil
+}
+
+func addChirpTagTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE chirps ADD COLUMN tags TEXT NOT NULL DEFAULT '';
+		CREATE TABLE IF NOT EXISTS chirp_tags (
+			tag        TEXT     NOT NULL,
+			chirp_id   INTEGER  NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (tag, chirp_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_chirp_tags_chirp_id ON chirp_tags (chirp_id);
+		CREATE INDEX IF NOT EXISTS idx_chirp_tags_created_at ON chirp_tags (created_at);
+	`)
+	if err != nil {
+		return err
+	}
+
+	var chirps []models.Chirp
+	err = scanRows(tx, "SELECT id, body, created_at FROM chirps WHERE deleted = 0", func(rows *sql.Rows) error {
+		var chirp models.Chirp
+		if err := rows.Scan(&chirp.ID, &chirp.Body, &chirp.CreatedAt); err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, chirp := range chirps {
+		tags := parseTagsV9(chirp.Body)
+		if len(tags) == 0 {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE chirps SET tags = ? WHERE id = ?", strings.Join(tags, " "), chirp.ID); err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if _, err := tx.Exec("INSERT INTO chirp_tags (tag, chirp_id, created_at) VALUES (?, ?, ?)", tag, chirp.ID, chirp.CreatedAt.UTC()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// searchTermsV13 is how migration 13 split chirp bodies into search terms:
+// the distinct case folded words, without punctuation. Like parseTagsV9, it
+// is a copy that later changes to searchTerms leave alone.
+func searchTermsV13(body string) []string {
+	terms := strings.FieldsFunc(cases.Fold().String(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	slices.Sort(terms)
+
+	return slices.Compact(terms)
+}
+
+func addChirpTermTable(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS chirp_terms (
+			term     TEXT    NOT NULL,
+			chirp_id INTEGER NOT NULL,
+			PRIMARY KEY (term, chirp_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_chirp_terms_chirp_id ON chirp_terms (chirp_id);
+	`)
+	if err != nil {
+		return err
+	}
+
+	var chirps []models.Chirp
+	err = scanRows(tx, "SELECT id, body FROM chirps WHERE deleted = 0", func(rows *sql.Rows) error {
+		var chirp models.Chirp
+		if err := rows.Scan(&chirp.ID, &chirp.Body); err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, chirp := range chirps {
+		for _, term := range searchTermsV13(chirp.Body) {
+			if _, err := tx.Exec("INSERT INTO chirp_terms (term, chirp_id) VALUES (?, ?)", term, chirp.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
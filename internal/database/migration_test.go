@@ -0,0 +1,203 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type migrationFixture struct {
+	name    string
+	load    func(t *testing.T, dir string) string
+	migrate func(path string, dryRun bool) (int, []Migration, error)
+	open    func(path string) (Store, error)
+}
+
+var migrationFixtures = []migrationFixture{
+	{
+		name: "json",
+		load: func(t *testing.T, dir string) string {
+			data, err := os.ReadFile(filepath.Join("testdata", "v0.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "database.json")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		},
+		migrate: MigrateJSON,
+		open: func(path string) (Store, error) {
+			return NewDB(path, Durability{SyncEveryWrite: true})
+		},
+	},
+	{
+		name: "sqlite",
+		load: func(t *testing.T, dir string) string {
+			statements, err := os.ReadFile(filepath.Join("testdata", "v0.sql"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "database.db")
+			// Not openSQLite, which would create and migrate a new schema.
+			db, err := sql.Open("sqlite", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(string(statements)); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		},
+		migrate: MigrateSQLite,
+		open: func(path string) (Store, error) {
+			return NewSQLiteDB(path)
+		},
+	},
+}
+
+func TestMigrateFixtures(t *testing.T) {
+	for _, fx := range migrationFixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			path := fx.load(t, t.TempDir())
+
+			if _, err := fx.open(path); err == nil {
+				t.Fatal("opened a version 0 store without migrating it")
+			}
+
+			from, applied, err := fx.migrate(path, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != 0 {
+				t.Errorf("migrated from version %d, want 0", from)
+			}
+			if len(applied) != len(migrations) {
+				t.Errorf("applied %d migrations, want %d", len(applied), len(migrations))
+			}
+
+			store, err := fx.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			for _, id := range []string{"1", "2", "5"} {
+				if _, err := store.GetChirp(id); err != nil {
+					t.Errorf("chirp %s: %v", id, err)
+				}
+			}
+			chirp, err := store.CreateChirp(models.Chirp{Body: "after the migration", AuthorID: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chirp.ID != 6 {
+				t.Errorf("new chirp got ID %d, want 6", chirp.ID)
+			}
+
+			for email, wantID := range map[string]int{"alice@example.com": 1, "ALICE@EXAMPLE.COM": 1, "Bob@Example.com": 2} {
+				user, err := store.GetUserByEmail(email)
+				if err != nil {
+					t.Errorf("user %s: %v", email, err)
+					continue
+				}
+				if user.ID != wantID {
+					t.Errorf("user %s has ID %d, want %d", email, user.ID, wantID)
+				}
+			}
+			if user, err := store.GetUserByID(2); err != nil || !user.PremiumMember {
+				t.Errorf("user 2 lost its membership: %+v, %v", user, err)
+			}
+
+			for tag, wantIDs := range map[string][]int{"go": {1, 5}, "sqlite": {5}, "golang": nil} {
+				page, err := store.ListChirps(ChirpQuery{Tag: tag, SortOrder: "asc"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				var ids []int
+				for _, chirp := range page.Chirps {
+					ids = append(ids, chirp.ID)
+				}
+				if !slices.Equal(ids, wantIDs) {
+					t.Errorf("chirps tagged %s are %v, want %v", tag, ids, wantIDs)
+				}
+			}
+
+			for search, wantIDs := range map[string][]int{"learn": {1}, "together": {5}, "tags here": {2}, "sqlite": {5}} {
+				chirps, err := store.SearchChirps(ParseSearchQuery(search))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var ids []int
+				for _, chirp := range chirps {
+					ids = append(ids, chirp.ID)
+				}
+				slices.Sort(ids)
+				if !slices.Equal(ids, wantIDs) {
+					t.Errorf("search %q found %v, want %v", search, ids, wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrateFixturesDryRun(t *testing.T) {
+	for _, fx := range migrationFixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			path := fx.load(t, t.TempDir())
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			from, applied, err := fx.migrate(path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != 0 || len(applied) != len(migrations) {
+				t.Errorf("dry run reported version %d and %d migrations, want 0 and %d", from, len(applied), len(migrations))
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Error("dry run changed the file")
+			}
+
+			from, _, err = fx.migrate(path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != 0 {
+				t.Errorf("store is at version %d after a dry run, want 0", from)
+			}
+		})
+	}
+}
+
+func TestMigrateMissingFileDryRun(t *testing.T) {
+	for _, fx := range migrationFixtures {
+		t.Run(fx.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "missing")
+
+			from, applied, err := fx.migrate(path, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if from != SchemaVersion() || len(applied) != 0 {
+				t.Errorf("dry run reported version %d and %d migrations, want %d and none", from, len(applied), SchemaVersion())
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("dry run created %s", path)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// NotificationQuery selects a user's notifications, newest first. A zero
+// Limit returns all of them.
+type NotificationQuery struct {
+	UnreadOnly bool
+	// Before only returns notifications older than the one with this ID.
+	Before int
+	Limit  int
+}
+
+func (q NotificationQuery) matches(notification models.Notification) bool {
+	if q.UnreadOnly && notification.Read {
+		return false
+	}
+
+	return q.Before == 0 || notification.ID < q.Before
+}
+
+func (q NotificationQuery) limit() int {
+	if q.Limit <= 0 {
+		return 0
+	}
+
+	return min(q.Limit, MaxPageLimit)
+}
+
+// mentionPattern matches an @ that does not follow a word character, and
+// the handle after it.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,15})\b`)
+
+// parseMentions returns the distinct handles @mentioned in body, folded, in
+// the order they first appear.
+func parseMentions(body string) []string {
+	var handles []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		handle := handleKey(match[1])
+		if !slices.Contains(handles, handle) {
+			handles = append(handles, handle)
+		}
+	}
+
+	return handles
+}
+
+// notifier is a transaction that can also record notifications.
+type notifier interface {
+	Tx
+	notify(notification models.Notification) error
+}
+
+// notifyNewChirp tells the author of the chirp being replied to about the
+// reply, and everyone else chirp mentions about the mention.
+func notifyNewChirp(tx notifier, chirp models.Chirp) error {
+	notified := map[int]bool{chirp.AuthorID: true}
+	if chirp.InReplyTo != 0 {
+		parent, err := tx.GetChirp(strconv.Itoa(chirp.InReplyTo))
+		if err == nil && !parent.Deleted && !notified[parent.AuthorID] {
+			notified[parent.AuthorID] = true
+			if err := tx.notify(models.Notification{UserID: parent.AuthorID, Kind: models.NotificationReply, ActorID: chirp.AuthorID, ChirpID: chirp.ID}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return notifyMentions(tx, chirp, parseMentions(chirp.Body), notified)
+}
+
+// notifyEditedChirp only tells the users an edit mentions for the first time.
+func notifyEditedChirp(tx notifier, chirp models.Chirp, previousBody string) error {
+	previous := parseMentions(previousBody)
+
+	var added []string
+	for _, handle := range parseMentions(chirp.Body) {
+		if !slices.Contains(previous, handle) {
+			added = append(added, handle)
+		}
+	}
+
+	return notifyMentions(tx, chirp, added, map[int]bool{chirp.AuthorID: true})
+}
+
+// notifyMentions resolves handles to users and notifies those not notified
+// yet. Handles nobody has are ignored.
+func notifyMentions(tx notifier, chirp models.Chirp, handles []string, notified map[int]bool) error {
+	for _, handle := range handles {
+		user, err := tx.GetUserByHandle(handle)
+		if err != nil || notified[user.ID] {
+			continue
+		}
+		notified[user.ID] = true
+
+		if err := tx.notify(models.Notification{UserID: user.ID, Kind: models.NotificationMention, ActorID: chirp.AuthorID, ChirpID: chirp.ID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (tx *dbTx) notify(notification models.Notification) error {
+	id, err := tx.nextID("notifications")
+	if err != nil {
+		return err
+	}
+
+	notification.ID = id
+	notification.CreatedAt = time.Now().UTC()
+
+	return tx.put("notifications", strconv.Itoa(id), notification)
+}
+
+// unnotify withdraws the notifications of kind that actorID caused userID
+// about chirpID.
+func (tx *dbTx) unnotify(kind string, userID, actorID, chirpID int) error {
+	var ids []int
+	for id := range tx.db.indexes.notificationsByActor[actorID] {
+		notification := tx.db.data.Notifications[id]
+		if notification.Kind == kind && notification.UserID == userID && notification.ChirpID == chirpID {
+			ids = append(ids, id)
+		}
+	}
+
+	return tx.deleteNotifications(ids)
+}
+
+func (tx *dbTx) deleteNotifications(ids []int) error {
+	for _, id := range ids {
+		if err := tx.delete("notifications", strconv.Itoa(id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// notificationIDs lists the IDs in one of the notification indexes.
+func notificationIDs(sets map[int]map[int]struct{}, key int) []int {
+	ids := make([]int, 0, len(sets[key]))
+	for id := range sets[key] {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (db *DB) GetNotifications(userID int, query NotificationQuery) ([]models.Notification, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getNotifications(userID, query), nil
+}
+
+func (tx *dbTx) GetNotifications(userID int, query NotificationQuery) ([]models.Notification, error) {
+	return tx.db.getNotifications(userID, query), nil
+}
+
+func (db *DB) getNotifications(userID int, query NotificationQuery) []models.Notification {
+	notifications := make([]models.Notification, 0)
+	for id := range db.indexes.notifications[userID] {
+		if notification := db.data.Notifications[id]; query.matches(notification) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].ID > notifications[j].ID
+	})
+
+	if limit := query.limit(); limit > 0 && len(notifications) > limit {
+		notifications = notifications[:limit]
+	}
+
+	return notifications
+}
+
+func (db *DB) CountUnreadNotifications(userID int) (int, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.countUnreadNotifications(userID), nil
+}
+
+func (tx *dbTx) CountUnreadNotifications(userID int) (int, error) {
+	return tx.db.countUnreadNotifications(userID), nil
+}
+
+func (db *DB) countUnreadNotifications(userID int) int {
+	unread := 0
+	for id := range db.indexes.notifications[userID] {
+		if !db.data.Notifications[id].Read {
+			unread++
+		}
+	}
+
+	return unread
+}
+
+func (db *DB) MarkNotificationsRead(userID int) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.MarkNotificationsRead(userID)
+	})
+}
+
+func (tx *dbTx) MarkNotificationsRead(userID int) error {
+	for _, id := range notificationIDs(tx.db.indexes.notifications, userID) {
+		notification := tx.db.data.Notifications[id]
+		if notification.Read {
+			continue
+		}
+
+		notification.Read = true
+		if err := tx.put("notifications", strconv.Itoa(id), notification); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
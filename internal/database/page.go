@@ -0,0 +1,239 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// ChirpQuery selects chirps by combining all of its filters. Zero values leave
+// a filter unset, and a zero Limit returns every match in one page.
+type ChirpQuery struct {
+	AuthorIDs []int
+	SortOrder string
+	Since     time.Time
+	Until     time.Time
+	// Contains matches bodies containing the text, ignoring case.
+	Contains string
+	HasMedia *bool
+	// TimelineOf limits the chirps to those by this user and the users they
+	// follow.
+	TimelineOf int
+	// Tag limits the chirps to those using this folded hashtag.
+	Tag string
+	// BookmarkedBy limits the chirps to those this user bookmarked, and
+	// orders and pages them by when they were bookmarked.
+	BookmarkedBy int
+	// Unpinned leaves out pinned chirps, which an author feed lists apart.
+	Unpinned bool
+	Limit    int
+	Cursor   string
+}
+
+type ChirpPage struct {
+	Chirps     []models.Chirp
+	NextCursor string
+	PrevCursor string
+}
+
+const (
+	cursorNext = "next"
+	cursorPrev = "prev"
+)
+
+// cursor marks a position between chirps in (time, id) order and the
+// direction to read from it. The time is when the chirp was posted, or when
+// it was bookmarked in a list of bookmarks.
+type cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"i"`
+	Direction string    `json:"d"`
+}
+
+// before orders positions by time, then by ID.
+func (c cursor) before(other cursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.Before(other.CreatedAt)
+	}
+
+	return c.ID < other.ID
+}
+
+// chirpKey gives the position of a chirp in the order a listing is sorted
+// and paged in. The direction is left unset.
+type chirpKey func(chirp models.Chirp) cursor
+
+func byCreatedAt(chirp models.Chirp) cursor {
+	return cursor{CreatedAt: chirp.CreatedAt, ID: chirp.ID}
+}
+
+// sortByKey sorts chirps in key order, or in reverse if desc.
+func sortByKey(chirps []models.Chirp, key chirpKey, desc bool) {
+	sort.Slice(chirps, func(i, j int) bool {
+		if desc {
+			return key(chirps[j]).before(key(chirps[i]))
+		}
+		return key(chirps[i]).before(key(chirps[j]))
+	})
+}
+
+func encodeCursor(position cursor, direction string) string {
+	position.Direction = direction
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (q ChirpQuery) limit() int {
+	if q.Limit <= 0 {
+		return 0
+	}
+
+	if q.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+
+	return q.Limit
+}
+
+func (q ChirpQuery) matches(chirp models.Chirp) bool {
+	if chirp.Deleted {
+		return false
+	}
+
+	if len(q.AuthorIDs) > 0 && !containsInt(q.AuthorIDs, chirp.AuthorID) {
+		return false
+	}
+
+	if !q.Since.IsZero() && chirp.CreatedAt.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && !chirp.CreatedAt.Before(q.Until) {
+		return false
+	}
+
+	if q.Tag != "" && !slices.Contains(chirp.Tags, q.Tag) {
+		return false
+	}
+
+	if q.Contains != "" && !strings.Contains(strings.ToLower(chirp.Body), strings.ToLower(q.Contains)) {
+		return false
+	}
+
+	if q.HasMedia != nil && *q.HasMedia != (len(chirp.Media) > 0) {
+		return false
+	}
+
+	if q.Unpinned && chirp.PinnedAt != nil {
+		return false
+	}
+
+	return true
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// paginate cuts the page selected by q out of chirps, which must already be
+// filtered and sorted by key in q's order.
+func paginate(chirps []models.Chirp, q ChirpQuery, key chirpKey) (ChirpPage, error) {
+	limit := q.limit()
+	desc := q.SortOrder == "desc"
+
+	// before reports whether a sorts ahead of b in the requested order.
+	before := func(a, b cursor) bool {
+		if desc {
+			return b.before(a)
+		}
+		return a.before(b)
+	}
+
+	start, end := 0, len(chirps)
+	var c cursor
+	if q.Cursor != "" {
+		var err error
+		c, err = decodeCursor(q.Cursor)
+		if err != nil {
+			return ChirpPage{}, err
+		}
+
+		if c.Direction == cursorNext {
+			start = sort.Search(len(chirps), func(i int) bool { return before(c, key(chirps[i])) })
+		} else {
+			end = sort.Search(len(chirps), func(i int) bool { return !before(key(chirps[i]), c) })
+		}
+	}
+
+	window := chirps[start:end]
+	more := limit > 0 && len(window) > limit
+	if more {
+		if c.Direction == cursorPrev {
+			window = window[len(window)-limit:]
+		} else {
+			window = window[:limit]
+		}
+	}
+
+	return newChirpPage(window, q.Cursor != "", more, c.Direction, key), nil
+}
+
+// newChirpPage sets the cursors of a page read in direction. more tells
+// whether rows exist beyond the page in that direction; coming from a cursor
+// means there are rows on the other side. key gives the cursor positions.
+func newChirpPage(chirps []models.Chirp, fromCursor, more bool, direction string, key chirpKey) ChirpPage {
+	page := ChirpPage{Chirps: chirps}
+	if len(chirps) == 0 {
+		return page
+	}
+
+	hasNext, hasPrev := more, fromCursor
+	if direction == cursorPrev {
+		hasNext, hasPrev = fromCursor, more
+	}
+
+	if hasNext {
+		page.NextCursor = encodeCursor(key(chirps[len(chirps)-1]), cursorNext)
+	}
+	if hasPrev {
+		page.PrevCursor = encodeCursor(key(chirps[0]), cursorPrev)
+	}
+
+	return page
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestChirpCursors pages through chirps, most of which were posted at the
+// same time, so only their IDs tell the cursors apart. Reading back from the
+// last page must retrace the same pages.
+func TestChirpCursors(t *testing.T) {
+	sqlite, err := NewSQLiteDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dbContent := newDBStructure()
+	dbContent.Users[1] = models.User{ID: 1, Email: "alice@example.com", Password: []byte("hash")}
+	for id := 1; id <= 7; id++ {
+		// Chirps 2 to 6 share a timestamp; 7 is older than all of them.
+		createdAt := start.Add(time.Minute)
+		switch id {
+		case 1:
+			createdAt = start
+		case 7:
+			createdAt = start.Add(-time.Minute)
+		}
+		dbContent.Chirps[id] = models.Chirp{ID: id, Kind: models.ChirpKindChirp, Body: "chirp", AuthorID: 1, CreatedAt: createdAt, UpdatedAt: createdAt}
+	}
+	dbContent.Sequences["users"] = 1
+	dbContent.Sequences["chirps"] = 7
+
+	for name, store := range map[string]Store{"json": NewMemoryDB(), "sqlite": sqlite} {
+		if err := store.Restore(dbContent); err != nil {
+			t.Fatal(name, err)
+		}
+
+		for _, order := range []struct {
+			sortOrder string
+			want      []int
+		}{
+			{"asc", []int{7, 1, 2, 3, 4, 5, 6}},
+			{"desc", []int{6, 5, 4, 3, 2, 1, 7}},
+		} {
+			query := ChirpQuery{SortOrder: order.sortOrder, Limit: 2}
+			var pages [][]int
+			var page ChirpPage
+			for {
+				page, err = store.ListChirps(query)
+				if err != nil {
+					t.Fatal(name, err)
+				}
+				pages = append(pages, chirpIDs(page.Chirps))
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			if got := slices.Concat(pages...); !slices.Equal(got, order.want) {
+				t.Errorf("%s: %s pages are %v, want %v", name, order.sortOrder, pages, order.want)
+				continue
+			}
+
+			for i := len(pages) - 2; i >= 0; i-- {
+				if page.PrevCursor == "" {
+					t.Fatalf("%s: %s page %d has no previous cursor", name, order.sortOrder, i+1)
+				}
+				query.Cursor = page.PrevCursor
+				page, err = store.ListChirps(query)
+				if err != nil {
+					t.Fatal(name, err)
+				}
+				if got := chirpIDs(page.Chirps); !slices.Equal(got, pages[i]) {
+					t.Errorf("%s: %s page %d read backwards is %v, want %v", name, order.sortOrder, i, got, pages[i])
+				}
+			}
+			if page.PrevCursor != "" {
+				t.Errorf("%s: %s first page read backwards has a previous cursor", name, order.sortOrder)
+			}
+		}
+
+		for _, c := range []string{"not a cursor", base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2024-01-01T00:00:00Z","i":1,"d":"sideways"}`))} {
+			if _, err := store.ListChirps(ChirpQuery{Limit: 2, Cursor: c}); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("%s: cursor %q gave %v, want ErrInvalidCursor", name, c, err)
+			}
+		}
+	}
+}
+
+func chirpIDs(chirps []models.Chirp) []int {
+	ids := make([]int, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+
+	return ids
+}
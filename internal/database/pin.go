@@ -0,0 +1,94 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func (db *DB) PinChirp(id string) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.PinChirp(id)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) PinChirp(id string) (models.Chirp, error) {
+	return tx.setPinned(id, true)
+}
+
+func (db *DB) UnpinChirp(id string) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnpinChirp(id)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (tx *dbTx) UnpinChirp(id string) (models.Chirp, error) {
+	return tx.setPinned(id, false)
+}
+
+func (tx *dbTx) setPinned(id string, pinned bool) (models.Chirp, error) {
+	chirp, err := tx.db.getChirp(id)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if pinned == (chirp.PinnedAt != nil) {
+		return chirp, nil
+	}
+
+	chirp.PinnedAt = nil
+	if pinned {
+		now := time.Now().UTC()
+		chirp.PinnedAt = &now
+	}
+
+	if err := tx.put("chirps", strconv.Itoa(chirp.ID), chirp); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (db *DB) GetPinnedChirps(authorID int) ([]models.Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getPinnedChirps(authorID), nil
+}
+
+func (tx *dbTx) GetPinnedChirps(authorID int) ([]models.Chirp, error) {
+	return tx.db.getPinnedChirps(authorID), nil
+}
+
+func (db *DB) getPinnedChirps(authorID int) []models.Chirp {
+	chirps := []models.Chirp{}
+	for chirpID := range db.indexes.chirpsByAuthor[authorID] {
+		if chirp := db.data.Chirps[chirpID]; chirp.PinnedAt != nil {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	sort.Slice(chirps, func(i, j int) bool {
+		if !chirps[i].PinnedAt.Equal(*chirps[j].PinnedAt) {
+			return chirps[i].PinnedAt.After(*chirps[j].PinnedAt)
+		}
+		return chirps[i].ID > chirps[j].ID
+	})
+
+	return chirps
+}
@@ -0,0 +1,332 @@
+package database
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"golang.org/x/text/cases"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchQuery selects the chirps matching all of its parts.
+type SearchQuery struct {
+	// Terms match the words starting with them.
+	Terms []string
+	// Phrases match their words whole and in sequence.
+	Phrases [][]string
+	// From limits the results to the author with this handle.
+	From string
+	// Tags are folded hashtags the chirps must all use.
+	Tags  []string
+	Limit int
+}
+
+// searchTokenPattern splits a search into quoted phrases, unterminated ones
+// running to the end, and single words.
+var searchTokenPattern = regexp.MustCompile(`"[^"]*"?|\S+`)
+
+// ParseSearchQuery reads a search as typed by a user: words, "quoted
+// phrases", from:handle and #tag.
+func ParseSearchQuery(search string) SearchQuery {
+	var q SearchQuery
+	for _, token := range searchTokenPattern.FindAllString(search, -1) {
+		switch {
+		case strings.HasPrefix(token, `"`):
+			if phrase := tokenize(strings.Trim(token, `"`)); len(phrase) > 0 {
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		case len(token) > len("from:") && strings.EqualFold(token[:len("from:")], "from:"):
+			q.From = strings.TrimPrefix(token[len("from:"):], "@")
+			continue
+		case strings.HasPrefix(token, "#"):
+			if tags := parseTags(token); len(tags) > 0 {
+				for _, tag := range tags {
+					if !slices.Contains(q.Tags, tag) {
+						q.Tags = append(q.Tags, tag)
+					}
+				}
+				continue
+			}
+		}
+
+		for _, term := range tokenize(token) {
+			if !slices.Contains(q.Terms, term) {
+				q.Terms = append(q.Terms, term)
+			}
+		}
+	}
+
+	return q
+}
+
+// Empty reports whether the query has nothing to match on.
+func (q SearchQuery) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 && q.From == "" && len(q.Tags) == 0
+}
+
+// words are the distinct terms and phrase words, which a chirp must all have
+// a word starting with.
+func (q SearchQuery) words() []string {
+	words := slices.Clone(q.Terms)
+	for _, phrase := range q.Phrases {
+		for _, word := range phrase {
+			if !slices.Contains(words, word) {
+				words = append(words, word)
+			}
+		}
+	}
+
+	return words
+}
+
+// tokenize splits text into case folded words, dropping punctuation.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(cases.Fold().String(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// searchTerms lists the distinct words in body, which is what the search
+// index is keyed by.
+func searchTerms(body string) []string {
+	terms := tokenize(body)
+	slices.Sort(terms)
+
+	return slices.Compact(terms)
+}
+
+// termIndex maps the words in chirp bodies to the IDs of the chirps using
+// them. The words are also kept sorted, so that those starting with a prefix
+// are found next to each other without indexing every prefix.
+type termIndex struct {
+	chirps map[string]map[int]struct{}
+	terms  []string
+	// docs counts the chirps with any words.
+	docs int
+}
+
+func newTermIndex() *termIndex {
+	return &termIndex{chirps: make(map[string]map[int]struct{})}
+}
+
+func (ti *termIndex) add(chirpID int, body string) {
+	terms := searchTerms(body)
+	for _, term := range terms {
+		if _, ok := ti.chirps[term]; !ok {
+			i, _ := slices.BinarySearch(ti.terms, term)
+			ti.terms = slices.Insert(ti.terms, i, term)
+		}
+		addToSet(ti.chirps, term, chirpID)
+	}
+	if len(terms) > 0 {
+		ti.docs++
+	}
+}
+
+// remove is a no-op for chirps that were never added, such as tombstones.
+func (ti *termIndex) remove(chirpID int, body string) {
+	indexed := false
+	for _, term := range searchTerms(body) {
+		if _, ok := ti.chirps[term][chirpID]; !ok {
+			continue
+		}
+		indexed = true
+		removeFromSet(ti.chirps, term, chirpID)
+		if _, ok := ti.chirps[term]; !ok {
+			i, _ := slices.BinarySearch(ti.terms, term)
+			ti.terms = slices.Delete(ti.terms, i, i+1)
+		}
+	}
+	if indexed {
+		ti.docs--
+	}
+}
+
+// lookup returns the IDs of the chirps with a word starting with prefix.
+// The result must not be modified.
+func (ti *termIndex) lookup(prefix string) map[int]struct{} {
+	i, _ := slices.BinarySearch(ti.terms, prefix)
+	j := i
+	for j < len(ti.terms) && strings.HasPrefix(ti.terms[j], prefix) {
+		j++
+	}
+
+	switch j - i {
+	case 0:
+		return nil
+	case 1:
+		return ti.chirps[ti.terms[i]]
+	}
+
+	ids := make(map[int]struct{})
+	for _, term := range ti.terms[i:j] {
+		for id := range ti.chirps[term] {
+			ids[id] = struct{}{}
+		}
+	}
+
+	return ids
+}
+
+// searchStats are the figures ranking weighs words by: how many chirps are
+// indexed, and how many of them have a word starting with each query word.
+type searchStats struct {
+	docs int
+	df   map[string]int
+}
+
+// idf weighs rarer words higher.
+func (s searchStats) idf(word string) float64 {
+	return math.Log(1 + float64(s.docs)/float64(max(s.df[word], 1)))
+}
+
+// score rates how well chirp's body matches the query's words. Whole words
+// count more than prefixes and phrases count double; chirps missing a term
+// or phrase do not match.
+func (q SearchQuery) score(chirp models.Chirp, stats searchStats) (float64, bool) {
+	tokens := tokenize(chirp.Body)
+
+	score := 0.0
+	for _, term := range q.Terms {
+		tf := 0.0
+		for _, token := range tokens {
+			if token == term {
+				tf++
+			} else if strings.HasPrefix(token, term) {
+				tf += 0.5
+			}
+		}
+		if tf == 0 {
+			return 0, false
+		}
+		score += tf * stats.idf(term)
+	}
+
+	for _, phrase := range q.Phrases {
+		n := countPhrase(tokens, phrase)
+		if n == 0 {
+			return 0, false
+		}
+		for _, word := range phrase {
+			score += 2 * float64(n) * stats.idf(word)
+		}
+	}
+
+	// Among chirps using the words equally, the shorter is more about them.
+	if len(tokens) > 0 {
+		score /= math.Sqrt(float64(len(tokens)))
+	}
+
+	return score, true
+}
+
+func countPhrase(tokens, phrase []string) int {
+	n := 0
+	for i := 0; i+len(phrase) <= len(tokens); i++ {
+		if slices.Equal(tokens[i:i+len(phrase)], phrase) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// rankSearch orders the chirps matching the query best first, newest first
+// among equals.
+func rankSearch(chirps []models.Chirp, q SearchQuery, stats searchStats) []models.Chirp {
+	type result struct {
+		chirp models.Chirp
+		score float64
+	}
+
+	var results []result
+	for _, chirp := range chirps {
+		if score, ok := q.score(chirp, stats); ok {
+			results = append(results, result{chirp: chirp, score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		if !results[i].chirp.CreatedAt.Equal(results[j].chirp.CreatedAt) {
+			return results[i].chirp.CreatedAt.After(results[j].chirp.CreatedAt)
+		}
+		return results[i].chirp.ID > results[j].chirp.ID
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+
+	ranked := make([]models.Chirp, 0, len(results))
+	for _, result := range results {
+		ranked = append(ranked, result.chirp)
+	}
+
+	return ranked
+}
+
+func (db *DB) SearchChirps(q SearchQuery) ([]models.Chirp, error) {
+	if q.Empty() {
+		return []models.Chirp{}, errors.New("empty search query")
+	}
+
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	words := q.words()
+	stats := searchStats{docs: db.indexes.searchTerms.docs, df: make(map[string]int)}
+
+	// Every part of the query narrows the candidates down to one of the
+	// index's sets; the smallest is the one walked.
+	var sets []map[int]struct{}
+	for _, word := range words {
+		set := db.indexes.searchTerms.lookup(word)
+		stats.df[word] = len(set)
+		sets = append(sets, set)
+	}
+	for _, tag := range q.Tags {
+		sets = append(sets, db.indexes.tagged[tag])
+	}
+
+	authorID := 0
+	if q.From != "" {
+		id, ok := db.indexes.userByHandle[handleKey(q.From)]
+		if !ok {
+			return []models.Chirp{}, nil
+		}
+		authorID = id
+		sets = append(sets, db.indexes.chirpsByAuthor[authorID])
+	}
+
+	smallest := sets[0]
+	for _, set := range sets[1:] {
+		if len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+
+	var chirps []models.Chirp
+	for id := range smallest {
+		chirp := db.data.Chirps[id]
+		if chirp.Deleted || chirp.Kind == models.ChirpKindRechirp {
+			continue
+		}
+		if authorID != 0 && chirp.AuthorID != authorID {
+			continue
+		}
+		if slices.ContainsFunc(q.Tags, func(tag string) bool { return !slices.Contains(chirp.Tags, tag) }) {
+			continue
+		}
+		chirps = append(chirps, chirp)
+	}
+
+	return rankSearch(chirps, q, stats), nil
+}
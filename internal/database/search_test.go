@@ -0,0 +1,70 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTermIndex(t *testing.T) {
+	ti := newTermIndex()
+	ti.add(1, "Go gophers go")
+	ti.add(2, "going home")
+	ti.add(3, "!!!")
+
+	lookup := func(prefix string) []int {
+		var ids []int
+		for id := range ti.lookup(prefix) {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	for prefix, want := range map[string][]int{"go": {1, 2}, "gop": {1}, "going": {2}, "goings": nil, "h": {2}, "x": nil} {
+		if got := lookup(prefix); !slices.Equal(got, want) {
+			t.Errorf("lookup(%q) = %v, want %v", prefix, got, want)
+		}
+	}
+	if !slices.Equal(ti.terms, []string{"go", "going", "gophers", "home"}) {
+		t.Errorf("terms are %v", ti.terms)
+	}
+	if ti.docs != 2 {
+		t.Errorf("docs = %d, want 2", ti.docs)
+	}
+
+	ti.remove(1, "Go gophers go")
+	// Never added, like a tombstone.
+	ti.remove(4, "going nowhere")
+
+	if got := lookup("go"); !slices.Equal(got, []int{2}) {
+		t.Errorf("lookup(\"go\") after remove = %v, want [2]", got)
+	}
+	if !slices.Equal(ti.terms, []string{"going", "home"}) {
+		t.Errorf("terms after remove are %v", ti.terms)
+	}
+	if ti.docs != 1 {
+		t.Errorf("docs after remove = %d, want 1", ti.docs)
+	}
+}
+
+func TestSearchChirpsEmptyQuery(t *testing.T) {
+	sqlite, err := NewSQLiteDB(filepath.Join(t.TempDir(), "database.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlite.Close()
+
+	for name, store := range map[string]Store{"json": NewMemoryDB(), "sqlite": sqlite} {
+		if _, err := store.CreateChirp(models.Chirp{Body: "hello world", AuthorID: 1}); err != nil {
+			t.Fatal(name, err)
+		}
+
+		for _, search := range []string{"", "   ", "!!!"} {
+			if chirps, err := store.SearchChirps(ParseSearchQuery(search)); err == nil {
+				t.Errorf("%s: search %q returned %d chirps and no error", name, search, len(chirps))
+			}
+		}
+	}
+}
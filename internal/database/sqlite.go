@@ -0,0 +1,1699 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	_ "modernc.org/sqlite"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	email         TEXT    NOT NULL UNIQUE,
+	password      BLOB    NOT NULL,
+	is_chirpy_red INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS chirps (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	body      TEXT    NOT NULL,
+	author_id INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS invalid_refresh_tokens (
+	token      TEXT PRIMARY KEY,
+	revoked_at DATETIME NOT NULL
+);
+`
+
+type SQLiteDB struct {
+	sqliteTx
+	db *sql.DB
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// sqliteTx runs the store operations either directly on the database or
+// inside a transaction.
+type sqliteTx struct {
+	q querier
+}
+
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := sqliteUserVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if version != SchemaVersion() {
+		db.Close()
+		return nil, schemaVersionError(version)
+	}
+
+	return &SQLiteDB{sqliteTx: sqliteTx{q: db}, db: db}, nil
+}
+
+// openSQLite opens the database at path. A new database gets the version 0
+// schema and is migrated straight to the current version.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; sharing one connection also serializes
+	// transactions the way the JSON store's mutex does.
+	db.SetMaxOpenConns(1)
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'users')").Scan(&exists); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if !exists {
+		if _, err := db.Exec(sqliteSchema); err != nil {
+			db.Close()
+			return nil, err
+		}
+
+		if _, err := migrateSQLite(db, 0, false); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return db, nil
+}
+
+func (s *SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteDB) Tx(fn func(tx Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqliteTx{q: tx}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// readTx runs fn in a read-only transaction, so that several queries see
+// one consistent state without taking the write lock. Nothing fn does is
+// kept.
+func (s *SQLiteDB) readTx(fn func(tx Tx) error) error {
+	tx, err := s.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return fn(&sqliteTx{q: tx})
+}
+
+// chirpColumns are the columns scanChirp expects, in order. Times are always
+// stored in UTC so their text form sorts chronologically; pinned_at is NULL
+// unless the chirp is pinned.
+const chirpColumns = "id, body, author_id, created_at, updated_at, edited, in_reply_to, deleted, like_count, kind, original_id, tags, media, pinned_at"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// withColumn scans one more column into dest after those the caller asks for.
+type withColumn struct {
+	rowScanner
+	dest any
+}
+
+func (w withColumn) Scan(dest ...any) error {
+	return w.rowScanner.Scan(append(dest, w.dest)...)
+}
+
+func scanChirp(row rowScanner) (models.Chirp, error) {
+	var chirp models.Chirp
+	var tags, media string
+	var pinnedAt sql.NullTime
+	err := row.Scan(&chirp.ID, &chirp.Body, &chirp.AuthorID, &chirp.CreatedAt, &chirp.UpdatedAt, &chirp.Edited, &chirp.InReplyTo, &chirp.Deleted, &chirp.LikeCount, &chirp.Kind, &chirp.OriginalID, &tags, &media, &pinnedAt)
+	if err != nil {
+		return chirp, err
+	}
+
+	chirp.Tags = strings.Fields(tags)
+	if pinnedAt.Valid {
+		chirp.PinnedAt = &pinnedAt.Time
+	}
+	if media != "" {
+		err = json.Unmarshal([]byte(media), &chirp.Media)
+	}
+
+	return chirp, err
+}
+
+// mediaColumn encodes attachments for the chirp's media column, which holds
+// them as JSON and is empty when there are none.
+func mediaColumn(media []models.Attachment) (string, error) {
+	if len(media) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(media)
+	return string(data), err
+}
+
+// setTags replaces the hashtags a chirp is indexed under. The chirp's tags
+// column keeps them space separated, as tags never contain spaces.
+func setTags(q querier, chirpID int, createdAt time.Time, tags []string) error {
+	if _, err := q.Exec("DELETE FROM chirp_tags WHERE chirp_id = ?", chirpID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := q.Exec("INSERT INTO chirp_tags (tag, chirp_id, created_at) VALUES (?, ?, ?)", tag, chirpID, createdAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setSearchTerms replaces the words a chirp is found by in search.
+func setSearchTerms(q querier, chirpID int, body string) error {
+	if _, err := q.Exec("DELETE FROM chirp_terms WHERE chirp_id = ?", chirpID); err != nil {
+		return err
+	}
+
+	for _, term := range searchTerms(body) {
+		if _, err := q.Exec("INSERT INTO chirp_terms (term, chirp_id) VALUES (?, ?)", term, chirpID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLiteDB) CreateChirp(chirp models.Chirp) (models.Chirp, error) {
+	var newChirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		newChirp, err = tx.CreateChirp(chirp)
+		return err
+	})
+
+	return newChirp, err
+}
+
+func (s *sqliteTx) CreateChirp(chirp models.Chirp) (models.Chirp, error) {
+	kind := chirp.Kind
+	if kind == "" {
+		kind = models.ChirpKindChirp
+	}
+
+	now := time.Now().UTC()
+	tags := parseTags(chirp.Body)
+	media, err := mediaColumn(chirp.Media)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	res, err := s.q.Exec("INSERT INTO chirps (body, author_id, created_at, updated_at, in_reply_to, kind, original_id, tags, media) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", chirp.Body, chirp.AuthorID, now, now, chirp.InReplyTo, kind, chirp.OriginalID, strings.Join(tags, " "), media)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := setTags(s.q, int(id), now, tags); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := setSearchTerms(s.q, int(id), chirp.Body); err != nil {
+		return models.Chirp{}, err
+	}
+
+	newChirp := models.Chirp{ID: int(id), Kind: kind, Body: chirp.Body, Tags: tags, Media: chirp.Media, AuthorID: chirp.AuthorID, OriginalID: chirp.OriginalID, CreatedAt: now, UpdatedAt: now, InReplyTo: chirp.InReplyTo}
+	if err := notifyNewChirp(s, newChirp); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return newChirp, nil
+}
+
+// ListChirps pages with a keyset on (created_at, id). Reading backwards flips
+// the comparison and the order, and the rows are reversed afterwards. A list
+// of bookmarks is keyed on when they were bookmarked instead.
+func (s *SQLiteDB) ListChirps(q ChirpQuery) (ChirpPage, error) {
+	// orderedAt is the time column of the keyset, selected after the chirp.
+	orderedAt := "chirps.created_at"
+	from := "chirps"
+	var args []any
+	if q.BookmarkedBy != 0 {
+		orderedAt = "bookmarks.created_at"
+		from += " JOIN bookmarks ON bookmarks.chirp_id = chirps.id AND bookmarks.user_id = ?"
+		args = append(args, q.BookmarkedBy)
+	}
+
+	query := "SELECT " + chirpColumnsOf("chirps") + ", " + orderedAt + " FROM " + from + " WHERE 1 = 1"
+	query += " AND deleted = 0"
+	if len(q.AuthorIDs) > 0 {
+		query += " AND author_id IN (?" + strings.Repeat(", ?", len(q.AuthorIDs)-1) + ")"
+		for _, authorID := range q.AuthorIDs {
+			args = append(args, authorID)
+		}
+	}
+	if q.TimelineOf != 0 {
+		query += " AND (author_id = ? OR author_id IN (SELECT followee_id FROM follows WHERE follower_id = ?))"
+		args = append(args, q.TimelineOf, q.TimelineOf)
+	}
+	if q.Tag != "" {
+		query += " AND chirps.id IN (SELECT chirp_id FROM chirp_tags WHERE tag = ?)"
+		args = append(args, q.Tag)
+	}
+	if q.Unpinned {
+		query += " AND pinned_at IS NULL"
+	}
+	if !q.Since.IsZero() {
+		query += " AND chirps.created_at >= ?"
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		query += " AND chirps.created_at < ?"
+		args = append(args, q.Until.UTC())
+	}
+	if q.Contains != "" {
+		query += " AND instr(lower(body), lower(?)) > 0"
+		args = append(args, q.Contains)
+	}
+	if q.HasMedia != nil {
+		if *q.HasMedia {
+			query += " AND media <> ''"
+		} else {
+			query += " AND media = ''"
+		}
+	}
+
+	// descending is the order rows are read in, after the cursor direction.
+	descending := q.SortOrder == "desc"
+	var c cursor
+	if q.Cursor != "" {
+		var err error
+		c, err = decodeCursor(q.Cursor)
+		if err != nil {
+			return ChirpPage{}, err
+		}
+
+		if c.Direction == cursorPrev {
+			descending = !descending
+		}
+		if descending {
+			query += " AND (" + orderedAt + ", chirps.id) < (?, ?)"
+		} else {
+			query += " AND (" + orderedAt + ", chirps.id) > (?, ?)"
+		}
+		args = append(args, c.CreatedAt.UTC(), c.ID)
+	}
+
+	if descending {
+		query += " ORDER BY " + orderedAt + " DESC, chirps.id DESC"
+	} else {
+		query += " ORDER BY " + orderedAt + " ASC, chirps.id ASC"
+	}
+
+	limit := q.limit()
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit+1)
+	}
+
+	chirps := make([]models.Chirp, 0)
+	positions := make(map[int]time.Time)
+	err := scanRows(s.q, query, func(rows *sql.Rows) error {
+		var at time.Time
+		chirp, err := scanChirp(withColumn{rows, &at})
+		if err != nil {
+			return err
+		}
+		chirps = append(chirps, chirp)
+		positions[chirp.ID] = at
+		return nil
+	}, args...)
+	if err != nil {
+		return ChirpPage{}, err
+	}
+
+	more := limit > 0 && len(chirps) > limit
+	if more {
+		chirps = chirps[:limit]
+	}
+	if c.Direction == cursorPrev {
+		slices.Reverse(chirps)
+	}
+
+	return newChirpPage(chirps, q.Cursor != "", more, c.Direction, func(chirp models.Chirp) cursor {
+		return cursor{CreatedAt: positions[chirp.ID], ID: chirp.ID}
+	}), nil
+}
+
+// chirpColumnsOf qualifies chirpColumns with table, for queries that join
+// chirps with another table.
+func chirpColumnsOf(table string) string {
+	return table + "." + strings.ReplaceAll(chirpColumns, ", ", ", "+table+".")
+}
+
+func (s *SQLiteDB) LikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.LikeChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) LikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	return s.setLike(chirpID, userID, true)
+}
+
+func (s *SQLiteDB) UnlikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnlikeChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) UnlikeChirp(chirpID string, userID int) (models.Chirp, error) {
+	return s.setLike(chirpID, userID, false)
+}
+
+// setLike adds or removes a like, adjusting the chirp's like count only when
+// the like actually changed.
+func (s *sqliteTx) setLike(chirpID string, userID int, liked bool) (models.Chirp, error) {
+	chirp, err := s.GetChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	var res sql.Result
+	delta := 1
+	if liked {
+		res, err = s.q.Exec("INSERT INTO likes (chirp_id, user_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", chirp.ID, userID, time.Now().UTC())
+	} else {
+		res, err = s.q.Exec("DELETE FROM likes WHERE chirp_id = ? AND user_id = ?", chirp.ID, userID)
+		delta = -1
+	}
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return chirp, err
+	}
+
+	if _, err := s.q.Exec("UPDATE chirps SET like_count = like_count + ? WHERE id = ?", delta, chirp.ID); err != nil {
+		return models.Chirp{}, err
+	}
+	chirp.LikeCount += delta
+
+	if !liked {
+		err = s.unnotify(models.NotificationLike, chirp.AuthorID, userID, chirp.ID)
+	} else if chirp.AuthorID != userID {
+		err = s.notify(models.Notification{UserID: chirp.AuthorID, Kind: models.NotificationLike, ActorID: userID, ChirpID: chirp.ID})
+	}
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (s *SQLiteDB) BookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.BookmarkChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) BookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := s.GetChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if _, err := s.q.Exec("INSERT INTO bookmarks (chirp_id, user_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", chirp.ID, userID, time.Now().UTC()); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (s *SQLiteDB) UnbookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnbookmarkChirp(chirpID, userID)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) UnbookmarkChirp(chirpID string, userID int) (models.Chirp, error) {
+	chirp, err := s.GetChirp(chirpID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if _, err := s.q.Exec("DELETE FROM bookmarks WHERE chirp_id = ? AND user_id = ?", chirp.ID, userID); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (s *SQLiteDB) PinChirp(id string) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.PinChirp(id)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) PinChirp(id string) (models.Chirp, error) {
+	return s.setPinned(id, true)
+}
+
+func (s *SQLiteDB) UnpinChirp(id string) (models.Chirp, error) {
+	var chirp models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		chirp, err = tx.UnpinChirp(id)
+		return err
+	})
+
+	return chirp, err
+}
+
+func (s *sqliteTx) UnpinChirp(id string) (models.Chirp, error) {
+	return s.setPinned(id, false)
+}
+
+func (s *sqliteTx) setPinned(id string, pinned bool) (models.Chirp, error) {
+	chirp, err := s.GetChirp(id)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if chirp.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if pinned == (chirp.PinnedAt != nil) {
+		return chirp, nil
+	}
+
+	chirp.PinnedAt = nil
+	if pinned {
+		now := time.Now().UTC()
+		chirp.PinnedAt = &now
+	}
+
+	if _, err := s.q.Exec("UPDATE chirps SET pinned_at = ? WHERE id = ?", timeColumn(chirp.PinnedAt), chirp.ID); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (s *sqliteTx) GetPinnedChirps(authorID int) ([]models.Chirp, error) {
+	return s.queryChirps("SELECT "+chirpColumns+" FROM chirps WHERE author_id = ? AND pinned_at IS NOT NULL ORDER BY pinned_at DESC, id DESC", authorID)
+}
+
+func (s *sqliteTx) GetLikedChirps(userID int) ([]models.Chirp, error) {
+	return s.queryChirps("SELECT "+chirpColumnsOf("chirps")+" FROM likes JOIN chirps ON chirps.id = likes.chirp_id WHERE likes.user_id = ? ORDER BY likes.created_at DESC, chirps.id DESC", userID)
+}
+
+// idBatch keeps IN lists well below SQLite's limit on bound parameters.
+const idBatch = 500
+
+// inBatches calls fn with consecutive slices of ids of at most idBatch IDs,
+// along with a matching "(?, ?, ...)" placeholder list.
+func inBatches(ids []int, fn func(batch []any, placeholders string) error) error {
+	for len(ids) > 0 {
+		n := min(len(ids), idBatch)
+		batch := make([]any, n)
+		for i, id := range ids[:n] {
+			batch[i] = id
+		}
+		ids = ids[n:]
+
+		if err := fn(batch, "(?"+strings.Repeat(", ?", n-1)+")"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteTx) LikedChirpIDs(userID int, chirpIDs []int) (map[int]bool, error) {
+	liked := make(map[int]bool)
+	err := inBatches(chirpIDs, func(batch []any, placeholders string) error {
+		return s.scanIDs("SELECT chirp_id FROM likes WHERE user_id = ? AND chirp_id IN "+placeholders, func(chirpID int) {
+			liked[chirpID] = true
+		}, append([]any{userID}, batch...)...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return liked, nil
+}
+
+func (s *sqliteTx) GetChirpsByID(ids []int) (map[int]models.Chirp, error) {
+	chirps := make(map[int]models.Chirp, len(ids))
+	err := inBatches(ids, func(batch []any, placeholders string) error {
+		found, err := s.queryChirps("SELECT "+chirpColumns+" FROM chirps WHERE id IN "+placeholders, batch...)
+		for _, chirp := range found {
+			chirps[chirp.ID] = chirp
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chirps, nil
+}
+
+func (s *sqliteTx) GetRechirp(originalID string, userID int) (models.Chirp, error) {
+	id, err := strconv.Atoi(originalID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	chirp, err := scanChirp(s.q.QueryRow("SELECT "+chirpColumns+" FROM chirps WHERE kind = ? AND original_id = ? AND author_id = ?", models.ChirpKindRechirp, id, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// scanIDs runs a query selecting a single integer column and passes each
+// value to fn.
+func (s *sqliteTx) scanIDs(query string, fn func(id int), args ...any) error {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		fn(id)
+	}
+
+	return rows.Err()
+}
+
+func (s *sqliteTx) GetChirp(id string) (models.Chirp, error) {
+	intID, err := strconv.Atoi(id)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	chirp, err := scanChirp(s.q.QueryRow("SELECT "+chirpColumns+" FROM chirps WHERE id = ?", intID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (s *sqliteTx) DeleteChirp(id string) error {
+	chirp, err := s.GetChirp(id)
+	if err != nil {
+		return err
+	}
+
+	if chirp.Deleted {
+		return ErrChirpNotFound
+	}
+
+	if _, err := s.q.Exec("DELETE FROM chirp_revisions WHERE chirp_id = ?", chirp.ID); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM likes WHERE chirp_id = ?", chirp.ID); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM bookmarks WHERE chirp_id = ?", chirp.ID); err != nil {
+		return err
+	}
+
+	if err := setTags(s.q, chirp.ID, chirp.CreatedAt, nil); err != nil {
+		return err
+	}
+
+	if err := setSearchTerms(s.q, chirp.ID, ""); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM notifications WHERE chirp_id = ?", chirp.ID); err != nil {
+		return err
+	}
+
+	var rechirpIDs []int
+	err = s.scanIDs("SELECT id FROM chirps WHERE kind = ? AND original_id = ?", func(id int) {
+		rechirpIDs = append(rechirpIDs, id)
+	}, models.ChirpKindRechirp, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, rechirpID := range rechirpIDs {
+		if err := s.DeleteChirp(strconv.Itoa(rechirpID)); err != nil {
+			return err
+		}
+	}
+
+	hasReplies, err := s.hasReplies(chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	if hasReplies {
+		dead := tombstone(chirp)
+		_, err := s.q.Exec("UPDATE chirps SET body = '', tags = '', media = '', author_id = 0, original_id = 0, edited = 0, deleted = 1, like_count = 0, pinned_at = NULL, updated_at = ? WHERE id = ?", dead.UpdatedAt, chirp.ID)
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM chirps WHERE id = ?", chirp.ID); err != nil {
+		return err
+	}
+
+	return s.pruneTombstones(chirp.InReplyTo)
+}
+
+// pruneTombstones removes the tombstones above id in its thread that have no
+// replies left.
+func (s *sqliteTx) pruneTombstones(id int) error {
+	for id != 0 {
+		chirp, err := s.GetChirp(strconv.Itoa(id))
+		if err != nil || !chirp.Deleted {
+			return nil
+		}
+
+		hasReplies, err := s.hasReplies(id)
+		if err != nil || hasReplies {
+			return err
+		}
+
+		if _, err := s.q.Exec("DELETE FROM chirps WHERE id = ?", id); err != nil {
+			return err
+		}
+		id = chirp.InReplyTo
+	}
+
+	return nil
+}
+
+func (s *sqliteTx) hasReplies(id int) (bool, error) {
+	var exists bool
+	err := s.q.QueryRow("SELECT EXISTS (SELECT 1 FROM chirps WHERE in_reply_to = ?)", id).Scan(&exists)
+
+	return exists, err
+}
+
+func (s *sqliteTx) GetReplies(id string) ([]models.Chirp, error) {
+	chirp, err := s.GetChirp(id)
+	if err != nil {
+		return []models.Chirp{}, err
+	}
+
+	return s.queryChirps("SELECT "+chirpColumns+" FROM chirps WHERE in_reply_to = ? ORDER BY created_at ASC, id ASC", chirp.ID)
+}
+
+func (s *SQLiteDB) GetThread(id string, depth int) (models.ChirpThread, error) {
+	var thread models.ChirpThread
+	err := s.readTx(func(tx Tx) error {
+		var err error
+		thread, err = buildThread(tx, id, depth)
+		return err
+	})
+
+	return thread, err
+}
+
+func (s *SQLiteDB) DeleteChirp(id string) error {
+	return s.Tx(func(tx Tx) error {
+		return tx.DeleteChirp(id)
+	})
+}
+
+func (s *SQLiteDB) UpdateChirp(chirp models.Chirp) (models.Chirp, error) {
+	var updated models.Chirp
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		updated, err = tx.UpdateChirp(chirp)
+		return err
+	})
+
+	return updated, err
+}
+
+func (s *sqliteTx) UpdateChirp(chirp models.Chirp) (models.Chirp, error) {
+	current, err := s.GetChirp(strconv.Itoa(chirp.ID))
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	if current.Deleted {
+		return models.Chirp{}, ErrChirpNotFound
+	}
+
+	if current.Body == chirp.Body {
+		return current, nil
+	}
+
+	_, err = s.q.Exec("INSERT INTO chirp_revisions (chirp_id, revision, body, created_at) SELECT ?, COUNT(*) + 1, ?, ? FROM chirp_revisions WHERE chirp_id = ?", current.ID, current.Body, current.UpdatedAt.UTC(), current.ID)
+	if err != nil {
+		return models.Chirp{}, err
+	}
+
+	previousBody := current.Body
+	current.Body = chirp.Body
+	current.Tags = parseTags(chirp.Body)
+	current.Edited = true
+	current.UpdatedAt = time.Now().UTC()
+	if _, err := s.q.Exec("UPDATE chirps SET body = ?, tags = ?, edited = 1, updated_at = ? WHERE id = ?", current.Body, strings.Join(current.Tags, " "), current.UpdatedAt, current.ID); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := setTags(s.q, current.ID, current.CreatedAt, current.Tags); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := setSearchTerms(s.q, current.ID, current.Body); err != nil {
+		return models.Chirp{}, err
+	}
+
+	if err := notifyEditedChirp(s, current, previousBody); err != nil {
+		return models.Chirp{}, err
+	}
+
+	return current, nil
+}
+
+func (s *sqliteTx) GetChirpRevisions(id string) ([]models.ChirpRevision, error) {
+	chirp, err := s.GetChirp(id)
+	if err != nil {
+		return []models.ChirpRevision{}, err
+	}
+
+	if chirp.Deleted {
+		return []models.ChirpRevision{}, ErrChirpNotFound
+	}
+
+	rows, err := s.q.Query("SELECT revision, body, created_at FROM chirp_revisions WHERE chirp_id = ? ORDER BY revision", chirp.ID)
+	if err != nil {
+		return []models.ChirpRevision{}, err
+	}
+	defer rows.Close()
+
+	revisions := make([]models.ChirpRevision, 0)
+	for rows.Next() {
+		var revision models.ChirpRevision
+		if err := rows.Scan(&revision.Revision, &revision.Body, &revision.CreatedAt); err != nil {
+			return []models.ChirpRevision{}, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return []models.ChirpRevision{}, err
+	}
+
+	return append(revisions, models.ChirpRevision{Revision: len(revisions) + 1, Body: chirp.Body, CreatedAt: chirp.UpdatedAt}), nil
+}
+
+func (s *sqliteTx) GetChirpsByAuthorID(id string) ([]models.Chirp, error) {
+	authorID, err := strconv.Atoi(id)
+	if err != nil {
+		return []models.Chirp{}, err
+	}
+
+	return s.queryChirps("SELECT "+chirpColumns+" FROM chirps WHERE author_id = ? ORDER BY created_at ASC, id ASC", authorID)
+}
+
+func (s *sqliteTx) queryChirps(query string, args ...any) ([]models.Chirp, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return []models.Chirp{}, err
+	}
+	defer rows.Close()
+
+	chirps := make([]models.Chirp, 0)
+	for rows.Next() {
+		chirp, err := scanChirp(rows)
+		if err != nil {
+			return []models.Chirp{}, err
+		}
+		chirps = append(chirps, chirp)
+	}
+
+	return chirps, rows.Err()
+}
+
+func (s *sqliteTx) emailExists(email string) bool {
+	var exists bool
+	if err := s.q.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE email = ? COLLATE NOCASE)", email).Scan(&exists); err != nil {
+		return false
+	}
+
+	return exists
+}
+
+func (s *sqliteTx) handleExists(handle string) bool {
+	var exists bool
+	if err := s.q.QueryRow("SELECT EXISTS (SELECT 1 FROM users WHERE handle = ? COLLATE NOCASE AND handle <> '')", handle).Scan(&exists); err != nil {
+		return false
+	}
+
+	return exists
+}
+
+// CreateUser checks for an existing email and inserts in one transaction.
+func (s *SQLiteDB) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
+	var newUser models.User
+	err := s.Tx(func(tx Tx) error {
+		var err error
+		newUser, err = tx.CreateUser(signupReq)
+		return err
+	})
+
+	return newUser, err
+}
+
+func (s *sqliteTx) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
+	if err := validateSignUpRequest(signupReq, s.emailExists, s.handleExists); err != nil {
+		return models.User{}, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(signupReq.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	res, err := s.q.Exec("INSERT INTO users (email, handle, display_name, bio, avatar_url, password, is_chirpy_red) VALUES (?, ?, ?, ?, ?, ?, ?)", signupReq.Email, signupReq.Handle, signupReq.DisplayName, signupReq.Bio, signupReq.AvatarURL, hashedPassword, false)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return models.User{ID: int(id), Email: signupReq.Email, Handle: signupReq.Handle, DisplayName: signupReq.DisplayName, Bio: signupReq.Bio, AvatarURL: signupReq.AvatarURL, Password: hashedPassword, PremiumMember: false}, nil
+}
+
+func (s *sqliteTx) UpdateUser(user models.User) error {
+	_, err := s.q.Exec(
+		"INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?) "+
+			"ON CONFLICT (id) DO UPDATE SET email = excluded.email, handle = excluded.handle, display_name = excluded.display_name, bio = excluded.bio, avatar_url = excluded.avatar_url, password = excluded.password, is_chirpy_red = excluded.is_chirpy_red",
+		user.ID, user.Email, user.Handle, user.DisplayName, user.Bio, user.AvatarURL, user.Password, user.PremiumMember,
+	)
+
+	return err
+}
+
+func (s *SQLiteDB) DeleteUser(id int) error {
+	return s.Tx(func(tx Tx) error {
+		return tx.DeleteUser(id)
+	})
+}
+
+// DeleteUser removes the user together with all of their chirps.
+func (s *sqliteTx) DeleteUser(id int) error {
+	if _, err := s.GetUserByID(id); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("UPDATE chirps SET like_count = like_count - 1 WHERE id IN (SELECT chirp_id FROM likes WHERE user_id = ?)", id); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM likes WHERE user_id = ?", id); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM bookmarks WHERE user_id = ?", id); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM follows WHERE follower_id = ? OR followee_id = ?", id, id); err != nil {
+		return err
+	}
+
+	if _, err := s.q.Exec("DELETE FROM drafts WHERE author_id = ?", id); err != nil {
+		return err
+	}
+
+	chirps, err := s.queryChirps("SELECT "+chirpColumns+" FROM chirps WHERE author_id = ? AND deleted = 0", id)
+	if err != nil {
+		return err
+	}
+
+	// Deleting one chirp can prune tombstones the user wrote further up the
+	// same thread, so skip whatever is already gone.
+	for _, chirp := range chirps {
+		if current, err := s.GetChirp(strconv.Itoa(chirp.ID)); err != nil || current.Deleted {
+			continue
+		}
+		if err := s.DeleteChirp(strconv.Itoa(chirp.ID)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.q.Exec("DELETE FROM notifications WHERE user_id = ? OR actor_id = ?", id, id); err != nil {
+		return err
+	}
+
+	_, err = s.q.Exec("DELETE FROM users WHERE id = ?", id)
+
+	return err
+}
+
+// userColumns are the columns scanUser expects, in order.
+const userColumns = "id, email, handle, display_name, bio, avatar_url, password, is_chirpy_red"
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.Handle, &user.DisplayName, &user.Bio, &user.AvatarURL, &user.Password, &user.PremiumMember)
+
+	return user, err
+}
+
+func (s *sqliteTx) GetUserByEmail(email string) (models.User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE email = ? COLLATE NOCASE", email)
+}
+
+func (s *sqliteTx) GetUserByID(id int) (models.User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE id = ?", id)
+}
+
+func (s *sqliteTx) GetUserByHandle(handle string) (models.User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE handle = ? COLLATE NOCASE AND handle <> ''", handle)
+}
+
+func (s *sqliteTx) GetUsersByID(ids []int) (map[int]models.User, error) {
+	users := make(map[int]models.User, len(ids))
+	err := inBatches(ids, func(batch []any, placeholders string) error {
+		return scanRows(s.q, "SELECT "+userColumns+" FROM users WHERE id IN "+placeholders, func(rows *sql.Rows) error {
+			user, err := scanUser(rows)
+			if err != nil {
+				return err
+			}
+			users[user.ID] = user
+			return nil
+		}, batch...)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (s *sqliteTx) queryUser(query string, args ...any) (models.User, error) {
+	user, err := scanUser(s.q.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, errors.New("user not found")
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *SQLiteDB) TrendingTags(window, halfLife time.Duration, limit int) ([]models.TrendingTag, error) {
+	now := time.Now().UTC()
+
+	var uses []tagUse
+	err := scanRows(s.q, "SELECT tag, created_at FROM chirp_tags WHERE created_at >= ?", func(rows *sql.Rows) error {
+		var use tagUse
+		if err := rows.Scan(&use.tag, &use.createdAt); err != nil {
+			return err
+		}
+		uses = append(uses, use)
+		return nil
+	}, now.Add(-window))
+	if err != nil {
+		return []models.TrendingTag{}, err
+	}
+
+	return rankTags(uses, now, halfLife, limit), nil
+}
+
+// termRange gives the bounds of the terms starting with prefix. Text compares
+// byte by byte, and no UTF-8 text continues past U+10FFFF.
+func termRange(prefix string) (string, string) {
+	return prefix, prefix + "\U0010FFFF"
+}
+
+func (s *SQLiteDB) SearchChirps(q SearchQuery) ([]models.Chirp, error) {
+	if q.Empty() {
+		return []models.Chirp{}, errors.New("empty search query")
+	}
+
+	query := "SELECT " + chirpColumns + " FROM chirps WHERE deleted = 0 AND kind <> ?"
+	args := []any{models.ChirpKindRechirp}
+
+	words := q.words()
+	stats := searchStats{df: make(map[string]int)}
+	if err := s.q.QueryRow("SELECT COUNT(DISTINCT chirp_id) FROM chirp_terms").Scan(&stats.docs); err != nil {
+		return []models.Chirp{}, err
+	}
+
+	for _, word := range words {
+		from, to := termRange(word)
+		query += " AND id IN (SELECT chirp_id FROM chirp_terms WHERE term >= ? AND term < ?)"
+		args = append(args, from, to)
+
+		var df int
+		if err := s.q.QueryRow("SELECT COUNT(DISTINCT chirp_id) FROM chirp_terms WHERE term >= ? AND term < ?", from, to).Scan(&df); err != nil {
+			return []models.Chirp{}, err
+		}
+		stats.df[word] = df
+	}
+	for _, tag := range q.Tags {
+		query += " AND id IN (SELECT chirp_id FROM chirp_tags WHERE tag = ?)"
+		args = append(args, tag)
+	}
+	if q.From != "" {
+		query += " AND author_id = (SELECT id FROM users WHERE handle = ? COLLATE NOCASE)"
+		args = append(args, q.From)
+	}
+
+	chirps, err := s.queryChirps(query, args...)
+	if err != nil {
+		return []models.Chirp{}, err
+	}
+
+	return rankSearch(chirps, q, stats), nil
+}
+
+func (s *sqliteTx) Follow(followerID, followeeID int) error {
+	res, err := s.q.Exec("INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING", followerID, followeeID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	return s.notify(models.Notification{UserID: followeeID, Kind: models.NotificationFollow, ActorID: followerID})
+}
+
+func (s *sqliteTx) Unfollow(followerID, followeeID int) error {
+	res, err := s.q.Exec("DELETE FROM follows WHERE follower_id = ? AND followee_id = ?", followerID, followeeID)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return err
+	}
+
+	return s.unnotify(models.NotificationFollow, followeeID, followerID, 0)
+}
+
+func (s *sqliteTx) GetFollowers(userID int) ([]models.Follow, error) {
+	return s.queryFollows("SELECT follower_id, followee_id, created_at FROM follows WHERE followee_id = ? ORDER BY created_at DESC, follower_id DESC", userID)
+}
+
+func (s *sqliteTx) GetFollowing(userID int) ([]models.Follow, error) {
+	return s.queryFollows("SELECT follower_id, followee_id, created_at FROM follows WHERE follower_id = ? ORDER BY created_at DESC, followee_id DESC", userID)
+}
+
+func (s *sqliteTx) CountFollowers(userID int) (int, error) {
+	var count int
+	err := s.q.QueryRow("SELECT COUNT(*) FROM follows WHERE followee_id = ?", userID).Scan(&count)
+
+	return count, err
+}
+
+func (s *sqliteTx) CountFollowing(userID int) (int, error) {
+	var count int
+	err := s.q.QueryRow("SELECT COUNT(*) FROM follows WHERE follower_id = ?", userID).Scan(&count)
+
+	return count, err
+}
+
+func (s *sqliteTx) queryFollows(query string, args ...any) ([]models.Follow, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return []models.Follow{}, err
+	}
+	defer rows.Close()
+
+	follows := make([]models.Follow, 0)
+	for rows.Next() {
+		var follow models.Follow
+		if err := rows.Scan(&follow.FollowerID, &follow.FolloweeID, &follow.CreatedAt); err != nil {
+			return []models.Follow{}, err
+		}
+		follows = append(follows, follow)
+	}
+
+	return follows, rows.Err()
+}
+
+// notificationColumns are the columns scanNotification expects, in order.
+const notificationColumns = "id, user_id, kind, actor_id, chirp_id, created_at, read"
+
+func scanNotification(row rowScanner) (models.Notification, error) {
+	var notification models.Notification
+	err := row.Scan(&notification.ID, &notification.UserID, &notification.Kind, &notification.ActorID, &notification.ChirpID, &notification.CreatedAt, &notification.Read)
+
+	return notification, err
+}
+
+func (s *sqliteTx) notify(notification models.Notification) error {
+	_, err := s.q.Exec("INSERT INTO notifications (user_id, kind, actor_id, chirp_id, created_at) VALUES (?, ?, ?, ?, ?)", notification.UserID, notification.Kind, notification.ActorID, notification.ChirpID, time.Now().UTC())
+
+	return err
+}
+
+func (s *sqliteTx) unnotify(kind string, userID, actorID, chirpID int) error {
+	_, err := s.q.Exec("DELETE FROM notifications WHERE actor_id = ? AND kind = ? AND user_id = ? AND chirp_id = ?", actorID, kind, userID, chirpID)
+
+	return err
+}
+
+func (s *sqliteTx) GetNotifications(userID int, q NotificationQuery) ([]models.Notification, error) {
+	query := "SELECT " + notificationColumns + " FROM notifications WHERE user_id = ?"
+	args := []any{userID}
+	if q.UnreadOnly {
+		query += " AND read = 0"
+	}
+	if q.Before != 0 {
+		query += " AND id < ?"
+		args = append(args, q.Before)
+	}
+	query += " ORDER BY id DESC"
+	if limit := q.limit(); limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	notifications := make([]models.Notification, 0)
+	err := scanRows(s.q, query, func(rows *sql.Rows) error {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return err
+		}
+		notifications = append(notifications, notification)
+		return nil
+	}, args...)
+	if err != nil {
+		return []models.Notification{}, err
+	}
+
+	return notifications, nil
+}
+
+func (s *sqliteTx) CountUnreadNotifications(userID int) (int, error) {
+	var unread int
+	err := s.q.QueryRow("SELECT COUNT(*) FROM notifications WHERE user_id = ? AND read = 0", userID).Scan(&unread)
+
+	return unread, err
+}
+
+func (s *sqliteTx) MarkNotificationsRead(userID int) error {
+	_, err := s.q.Exec("UPDATE notifications SET read = 1 WHERE user_id = ? AND read = 0", userID)
+
+	return err
+}
+
+// draftColumns are the columns scanDraft expects, in order. publish_at is
+// NULL for drafts that are not scheduled.
+const draftColumns = "id, body, author_id, in_reply_to, original_id, media, publish_at, created_at, updated_at"
+
+func scanDraft(row rowScanner) (models.Draft, error) {
+	var draft models.Draft
+	var media string
+	var publishAt sql.NullTime
+	err := row.Scan(&draft.ID, &draft.Body, &draft.AuthorID, &draft.InReplyTo, &draft.OriginalID, &media, &publishAt, &draft.CreatedAt, &draft.UpdatedAt)
+	if err != nil {
+		return draft, err
+	}
+
+	if publishAt.Valid {
+		draft.PublishAt = &publishAt.Time
+	}
+	if media != "" {
+		err = json.Unmarshal([]byte(media), &draft.Media)
+	}
+
+	return draft, err
+}
+
+// timeColumn gives the value stored for an optional time, such as a draft's
+// schedule.
+func timeColumn(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return t.UTC()
+}
+
+func (s *sqliteTx) CreateDraft(draft models.Draft) (models.Draft, error) {
+	media, err := mediaColumn(draft.Media)
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	now := time.Now().UTC()
+	res, err := s.q.Exec("INSERT INTO drafts (body, author_id, in_reply_to, original_id, media, publish_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)", draft.Body, draft.AuthorID, draft.InReplyTo, draft.OriginalID, media, timeColumn(draft.PublishAt), now, now)
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	draft.ID = int(id)
+	draft.CreatedAt = now
+	draft.UpdatedAt = now
+
+	return draft, nil
+}
+
+func (s *sqliteTx) GetDraft(id int) (models.Draft, error) {
+	draft, err := scanDraft(s.q.QueryRow("SELECT "+draftColumns+" FROM drafts WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Draft{}, ErrDraftNotFound
+	}
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	return draft, nil
+}
+
+func (s *sqliteTx) GetDrafts(authorID int) ([]models.Draft, error) {
+	return s.queryDrafts("SELECT "+draftColumns+" FROM drafts WHERE author_id = ? ORDER BY id DESC", authorID)
+}
+
+func (s *SQLiteDB) GetDueDrafts(now time.Time) ([]models.Draft, error) {
+	return s.queryDrafts("SELECT "+draftColumns+" FROM drafts WHERE publish_at <= ? ORDER BY publish_at ASC, id ASC", now.UTC())
+}
+
+func (s *sqliteTx) queryDrafts(query string, args ...any) ([]models.Draft, error) {
+	drafts := make([]models.Draft, 0)
+	err := scanRows(s.q, query, func(rows *sql.Rows) error {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return err
+		}
+		drafts = append(drafts, draft)
+		return nil
+	}, args...)
+	if err != nil {
+		return []models.Draft{}, err
+	}
+
+	return drafts, nil
+}
+
+func (s *sqliteTx) UpdateDraft(draft models.Draft) (models.Draft, error) {
+	current, err := s.GetDraft(draft.ID)
+	if err != nil {
+		return models.Draft{}, err
+	}
+
+	current.Body = draft.Body
+	current.PublishAt = draft.PublishAt
+	current.UpdatedAt = time.Now().UTC()
+	if _, err := s.q.Exec("UPDATE drafts SET body = ?, publish_at = ?, updated_at = ? WHERE id = ?", current.Body, timeColumn(current.PublishAt), current.UpdatedAt, current.ID); err != nil {
+		return models.Draft{}, err
+	}
+
+	return current, nil
+}
+
+func (s *sqliteTx) DeleteDraft(id int) error {
+	res, err := s.q.Exec("DELETE FROM drafts WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDraftNotFound
+	}
+
+	return nil
+}
+
+func (s *sqliteTx) RefreshTokenIsInvalid(token string) bool {
+	var exists bool
+	if err := s.q.QueryRow("SELECT EXISTS (SELECT 1 FROM invalid_refresh_tokens WHERE token = ?)", token).Scan(&exists); err != nil {
+		return false
+	}
+
+	return exists
+}
+
+func (s *sqliteTx) InvalidateRefreshToken(token string) error {
+	_, err := s.q.Exec("INSERT OR REPLACE INTO invalid_refresh_tokens (token, revoked_at) VALUES (?, ?)", token, time.Now().UTC())
+
+	return err
+}
+
+// Restore replaces the whole content of the database with dbContent, which
+// must be at the current schema version.
+func (s *SQLiteDB) Restore(dbContent DBStructure) error {
+	if dbContent.Version != SchemaVersion() {
+		return schemaVersionError(dbContent.Version)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"chirps", "chirp_revisions", "chirp_tags", "chirp_terms", "likes", "bookmarks", "follows", "notifications", "drafts", "users", "invalid_refresh_tokens"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+
+	for _, user := range dbContent.Users {
+		if _, err := tx.Exec("INSERT INTO users ("+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)", user.ID, user.Email, user.Handle, user.DisplayName, user.Bio, user.AvatarURL, user.Password, user.PremiumMember); err != nil {
+			return err
+		}
+	}
+
+	for _, chirp := range dbContent.Chirps {
+		media, err := mediaColumn(chirp.Media)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO chirps ("+chirpColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", chirp.ID, chirp.Body, chirp.AuthorID, chirp.CreatedAt.UTC(), chirp.UpdatedAt.UTC(), chirp.Edited, chirp.InReplyTo, chirp.Deleted, chirp.LikeCount, chirp.Kind, chirp.OriginalID, strings.Join(chirp.Tags, " "), media, timeColumn(chirp.PinnedAt)); err != nil {
+			return err
+		}
+		if err := setTags(tx, chirp.ID, chirp.CreatedAt, chirp.Tags); err != nil {
+			return err
+		}
+		if err := setSearchTerms(tx, chirp.ID, chirp.Body); err != nil {
+			return err
+		}
+	}
+
+	for _, like := range dbContent.Likes {
+		if _, err := tx.Exec("INSERT INTO likes (chirp_id, user_id, created_at) VALUES (?, ?, ?)", like.ChirpID, like.UserID, like.CreatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	for _, bookmark := range dbContent.Bookmarks {
+		if _, err := tx.Exec("INSERT INTO bookmarks (chirp_id, user_id, created_at) VALUES (?, ?, ?)", bookmark.ChirpID, bookmark.UserID, bookmark.CreatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	for _, notification := range dbContent.Notifications {
+		if _, err := tx.Exec("INSERT INTO notifications ("+notificationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)", notification.ID, notification.UserID, notification.Kind, notification.ActorID, notification.ChirpID, notification.CreatedAt.UTC(), notification.Read); err != nil {
+			return err
+		}
+	}
+
+	for _, draft := range dbContent.Drafts {
+		media, err := mediaColumn(draft.Media)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO drafts ("+draftColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", draft.ID, draft.Body, draft.AuthorID, draft.InReplyTo, draft.OriginalID, media, timeColumn(draft.PublishAt), draft.CreatedAt.UTC(), draft.UpdatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	for _, follow := range dbContent.Follows {
+		if _, err := tx.Exec("INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?)", follow.FollowerID, follow.FolloweeID, follow.CreatedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	for chirpID, revisions := range dbContent.Revisions {
+		for _, revision := range revisions {
+			if _, err := tx.Exec("INSERT INTO chirp_revisions (chirp_id, revision, body, created_at) VALUES (?, ?, ?, ?)", chirpID, revision.Revision, revision.Body, revision.CreatedAt.UTC()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for token, revokedAt := range dbContent.InvalidRefreshTokens {
+		if _, err := tx.Exec("INSERT INTO invalid_refresh_tokens (token, revoked_at) VALUES (?, ?)", token, revokedAt.UTC()); err != nil {
+			return err
+		}
+	}
+
+	// Carry over the sequences so IDs deleted before the snapshot are not reused.
+	for _, table := range []string{"chirps", "users", "notifications", "drafts"} {
+		seq := dbContent.Sequences[table]
+		if _, err := tx.Exec("INSERT INTO sqlite_sequence (name, seq) SELECT ?, 0 WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = ?)", table, table); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE sqlite_sequence SET seq = MAX(seq, ?) WHERE name = ?", seq, table); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Snapshot returns a consistent copy of the whole database.
+func (s *SQLiteDB) Snapshot() (DBStructure, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return DBStructure{}, err
+	}
+	defer tx.Rollback()
+
+	dbContent := newDBStructure()
+
+	err = scanRows(tx, "SELECT "+userColumns+" FROM users", func(rows *sql.Rows) error {
+		user, err := scanUser(rows)
+		if err != nil {
+			return err
+		}
+		dbContent.Users[user.ID] = user
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT "+chirpColumns+" FROM chirps", func(rows *sql.Rows) error {
+		chirp, err := scanChirp(rows)
+		if err != nil {
+			return err
+		}
+		dbContent.Chirps[chirp.ID] = chirp
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT chirp_id, revision, body, created_at FROM chirp_revisions ORDER BY chirp_id, revision", func(rows *sql.Rows) error {
+		var chirpID int
+		var revision models.ChirpRevision
+		if err := rows.Scan(&chirpID, &revision.Revision, &revision.Body, &revision.CreatedAt); err != nil {
+			return err
+		}
+		dbContent.Revisions[chirpID] = append(dbContent.Revisions[chirpID], revision)
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT chirp_id, user_id, created_at FROM likes", func(rows *sql.Rows) error {
+		var like models.Like
+		if err := rows.Scan(&like.ChirpID, &like.UserID, &like.CreatedAt); err != nil {
+			return err
+		}
+		dbContent.Likes[likeKey(like.ChirpID, like.UserID)] = like
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT chirp_id, user_id, created_at FROM bookmarks", func(rows *sql.Rows) error {
+		var bookmark models.Bookmark
+		if err := rows.Scan(&bookmark.ChirpID, &bookmark.UserID, &bookmark.CreatedAt); err != nil {
+			return err
+		}
+		dbContent.Bookmarks[bookmarkKey(bookmark.ChirpID, bookmark.UserID)] = bookmark
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT follower_id, followee_id, created_at FROM follows", func(rows *sql.Rows) error {
+		var follow models.Follow
+		if err := rows.Scan(&follow.FollowerID, &follow.FolloweeID, &follow.CreatedAt); err != nil {
+			return err
+		}
+		dbContent.Follows[followKey(follow.FollowerID, follow.FolloweeID)] = follow
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT "+notificationColumns+" FROM notifications", func(rows *sql.Rows) error {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return err
+		}
+		dbContent.Notifications[notification.ID] = notification
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT "+draftColumns+" FROM drafts", func(rows *sql.Rows) error {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return err
+		}
+		dbContent.Drafts[draft.ID] = draft
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT token, revoked_at FROM invalid_refresh_tokens", func(rows *sql.Rows) error {
+		var token string
+		var revokedAt time.Time
+		if err := rows.Scan(&token, &revokedAt); err != nil {
+			return err
+		}
+		dbContent.InvalidRefreshTokens[token] = revokedAt
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	err = scanRows(tx, "SELECT name, seq FROM sqlite_sequence", func(rows *sql.Rows) error {
+		var name string
+		var seq int
+		if err := rows.Scan(&name, &seq); err != nil {
+			return err
+		}
+		dbContent.Sequences[name] = seq
+		return nil
+	})
+	if err != nil {
+		return DBStructure{}, err
+	}
+
+	return dbContent, nil
+}
+
+func scanRows(q querier, query string, scan func(rows *sql.Rows) error, args ...any) error {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,173 @@
+package database
+
+import (
+	"encoding/json"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// storage is where a DB persists its resident DBStructure.
+// Changes are first appended to the log and then folded into a new snapshot,
+// so whatever was logged survives a crash in between.
+type storage interface {
+	read() ([]byte, []walEntry, error)
+	log(entries []walEntry) error
+	write(data []byte) error
+}
+
+type fileStorage struct {
+	path    string
+	walPath string
+}
+
+func newFileStorage(path string) *fileStorage {
+	return &fileStorage{path: path, walPath: path + ".wal"}
+}
+
+func (fs *fileStorage) read() ([]byte, []walEntry, error) {
+	data, err := os.ReadFile(fs.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	entries, err := readWAL(fs.walPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, entries, nil
+}
+
+func (fs *fileStorage) log(entries []walEntry) error {
+	return appendWAL(fs.walPath, entries)
+}
+
+func (fs *fileStorage) write(data []byte) error {
+	if err := WriteFileAtomic(fs.path, data, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Remove(fs.walPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// memoryStorage backs a DB that lives only in its resident copy.
+type memoryStorage struct{}
+
+func (ms *memoryStorage) read() ([]byte, []walEntry, error) {
+	return nil, nil, nil
+}
+
+func (ms *memoryStorage) log(entries []walEntry) error {
+	return nil
+}
+
+func (ms *memoryStorage) write(data []byte) error {
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file next to path, fsyncs it and
+// renames it into place, so readers see either the old or the new content.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+func decodeDBStructure(data []byte) (DBStructure, error) {
+	dbContent := newDBStructure()
+	if len(data) == 0 {
+		return dbContent, nil
+	}
+
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return DBStructure{}, err
+	}
+
+	if header.Version != SchemaVersion() {
+		return DBStructure{}, schemaVersionError(header.Version)
+	}
+
+	if err := json.Unmarshal(data, &dbContent); err != nil {
+		return DBStructure{}, err
+	}
+
+	if dbContent.Chirps == nil {
+		dbContent.Chirps = make(map[int]models.Chirp)
+	}
+	if dbContent.Users == nil {
+		dbContent.Users = make(map[int]models.User)
+	}
+	if dbContent.InvalidRefreshTokens == nil {
+		dbContent.InvalidRefreshTokens = make(map[string]time.Time)
+	}
+	if dbContent.Sequences == nil {
+		dbContent.Sequences = make(map[string]int)
+	}
+	if dbContent.Revisions == nil {
+		dbContent.Revisions = make(map[int][]models.ChirpRevision)
+	}
+	if dbContent.Likes == nil {
+		dbContent.Likes = make(map[string]models.Like)
+	}
+	if dbContent.Follows == nil {
+		dbContent.Follows = make(map[string]models.Follow)
+	}
+	if dbContent.Notifications == nil {
+		dbContent.Notifications = make(map[int]models.Notification)
+	}
+	if dbContent.Drafts == nil {
+		dbContent.Drafts = make(map[int]models.Draft)
+	}
+	if dbContent.Bookmarks == nil {
+		dbContent.Bookmarks = make(map[string]models.Bookmark)
+	}
+
+	return dbContent, nil
+}
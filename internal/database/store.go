@@ -0,0 +1,111 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"time"
+)
+
+// Tx is the set of operations that can be combined atomically with Store.Tx.
+type Tx interface {
+	CreateChirp(chirp models.Chirp) (models.Chirp, error)
+	GetChirp(id string) (models.Chirp, error)
+	// GetChirpsByID looks up several chirps at once; missing IDs are left out.
+	GetChirpsByID(ids []int) (map[int]models.Chirp, error)
+	// GetRechirp finds the user's rechirp of a chirp.
+	GetRechirp(originalID string, userID int) (models.Chirp, error)
+	DeleteChirp(id string) error
+	// UpdateChirp replaces the body of chirp.ID, keeping the old one as a
+	// revision.
+	UpdateChirp(chirp models.Chirp) (models.Chirp, error)
+	// GetChirpRevisions lists every version of a chirp, oldest first, ending
+	// with the current one.
+	GetChirpRevisions(id string) ([]models.ChirpRevision, error)
+	// GetReplies lists the direct replies to a chirp, oldest first.
+	GetReplies(id string) ([]models.Chirp, error)
+	// LikeChirp and UnlikeChirp are idempotent and return the chirp with its
+	// updated like count.
+	LikeChirp(chirpID string, userID int) (models.Chirp, error)
+	UnlikeChirp(chirpID string, userID int) (models.Chirp, error)
+	// BookmarkChirp and UnbookmarkChirp are idempotent and return the chirp.
+	BookmarkChirp(chirpID string, userID int) (models.Chirp, error)
+	UnbookmarkChirp(chirpID string, userID int) (models.Chirp, error)
+	// PinChirp and UnpinChirp are idempotent and return the updated chirp.
+	PinChirp(id string) (models.Chirp, error)
+	UnpinChirp(id string) (models.Chirp, error)
+	// GetPinnedChirps lists the chirps a user has pinned, most recently
+	// pinned first.
+	GetPinnedChirps(authorID int) ([]models.Chirp, error)
+	// Follow and Unfollow are idempotent.
+	Follow(followerID, followeeID int) error
+	Unfollow(followerID, followeeID int) error
+	// GetFollowers and GetFollowing list a user's follow relations, newest
+	// first.
+	GetFollowers(userID int) ([]models.Follow, error)
+	GetFollowing(userID int) ([]models.Follow, error)
+	// CountFollowers and CountFollowing count the same relations without
+	// loading them.
+	CountFollowers(userID int) (int, error)
+	CountFollowing(userID int) (int, error)
+	// GetLikedChirps lists the chirps a user likes, most recently liked first.
+	GetLikedChirps(userID int) ([]models.Chirp, error)
+	// LikedChirpIDs reports which of chirpIDs the user likes.
+	LikedChirpIDs(userID int, chirpIDs []int) (map[int]bool, error)
+	GetChirpsByAuthorID(id string) ([]models.Chirp, error)
+
+	CreateUser(signupReq models.SignUpRequest) (models.User, error)
+	UpdateUser(user models.User) error
+	DeleteUser(id int) error
+	GetUserByEmail(email string) (models.User, error)
+	GetUserByID(id int) (models.User, error)
+	// GetUsersByID looks up several users at once; missing IDs are left out.
+	GetUsersByID(ids []int) (map[int]models.User, error)
+	// GetUserByHandle looks a user up by handle, ignoring case.
+	GetUserByHandle(handle string) (models.User, error)
+
+	CreateDraft(draft models.Draft) (models.Draft, error)
+	GetDraft(id int) (models.Draft, error)
+	// GetDrafts lists a user's drafts, newest first.
+	GetDrafts(authorID int) ([]models.Draft, error)
+	// UpdateDraft replaces the body and schedule of draft.ID.
+	UpdateDraft(draft models.Draft) (models.Draft, error)
+	DeleteDraft(id int) error
+
+	// GetNotifications lists a user's notifications, newest first.
+	GetNotifications(userID int, query NotificationQuery) ([]models.Notification, error)
+	CountUnreadNotifications(userID int) (int, error)
+	MarkNotificationsRead(userID int) error
+
+	RefreshTokenIsInvalid(token string) bool
+	InvalidateRefreshToken(token string) error
+}
+
+type Store interface {
+	Tx
+	// ListChirps returns one page of the chirps matching query.
+	ListChirps(query ChirpQuery) (ChirpPage, error)
+	// GetThread returns the whole conversation a chirp is part of, from its
+	// root down to depth levels of replies.
+	GetThread(id string, depth int) (models.ChirpThread, error)
+	// TrendingTags ranks the hashtags of the chirps posted in the last window,
+	// with each use weighing half as much every halfLife.
+	TrendingTags(window, halfLife time.Duration, limit int) ([]models.TrendingTag, error)
+	// SearchChirps returns the chirps matching query, best matches first. An
+	// empty query is an error.
+	SearchChirps(query SearchQuery) ([]models.Chirp, error)
+	// GetDueDrafts lists the drafts scheduled for now or earlier, in the
+	// order they were due.
+	GetDueDrafts(now time.Time) ([]models.Draft, error)
+
+	// Tx runs fn as a single atomic read-modify-write. If fn returns an
+	// error none of its changes are kept. fn must only use tx, not the Store.
+	Tx(fn func(tx Tx) error) error
+
+	Snapshot() (DBStructure, error)
+	Restore(dbContent DBStructure) error
+	Close() error
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*SQLiteDB)(nil)
+)
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"golang.org/x/text/cases"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultTrendingLimit = 10
+	MaxTrendingLimit     = 50
+)
+
+// hashtagPattern matches a # that does not follow a word character, and the
+// tag after it.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_&#])#([\p{L}\p{M}\p{N}_]+)`)
+
+// FoldTag normalizes a hashtag, with or without its #, so that every casing
+// of it compares equal.
+func FoldTag(tag string) string {
+	return cases.Fold().String(strings.TrimPrefix(tag, "#"))
+}
+
+// parseTags returns the distinct hashtags in body, folded, in the order they
+// first appear. Tags made only of digits are left out.
+func parseTags(body string) []string {
+	var tags []string
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := FoldTag(match[1])
+		if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// tagUse is one chirp using a tag, at the time it was posted.
+type tagUse struct {
+	tag       string
+	createdAt time.Time
+}
+
+// rankTags scores each tag by its uses, where a use counts for less the
+// older it is, halving every halfLife.
+func rankTags(uses []tagUse, now time.Time, halfLife time.Duration, limit int) []models.TrendingTag {
+	byTag := make(map[string]*models.TrendingTag)
+	for _, use := range uses {
+		trending, ok := byTag[use.tag]
+		if !ok {
+			trending = &models.TrendingTag{Tag: use.tag}
+			byTag[use.tag] = trending
+		}
+
+		age := max(now.Sub(use.createdAt), 0)
+		trending.Score += math.Exp2(-float64(age) / float64(halfLife))
+		trending.Count++
+	}
+
+	tags := make([]models.TrendingTag, 0, len(byTag))
+	for _, trending := range byTag {
+		tags = append(tags, *trending)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Score != tags[j].Score {
+			return tags[i].Score > tags[j].Score
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	return tags
+}
+
+func (db *DB) TrendingTags(window, halfLife time.Duration, limit int) ([]models.TrendingTag, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	now := time.Now().UTC()
+	since := now.Add(-window)
+
+	var uses []tagUse
+	for _, chirp := range db.data.Chirps {
+		if chirp.Deleted || chirp.CreatedAt.Before(since) {
+			continue
+		}
+		for _, tag := range chirp.Tags {
+			uses = append(uses, tagUse{tag: tag, createdAt: chirp.CreatedAt})
+		}
+	}
+
+	return rankTags(uses, now, halfLife, limit), nil
+}
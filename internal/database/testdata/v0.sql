@@ -0,0 +1,26 @@
+CREATE TABLE users (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	email         TEXT    NOT NULL UNIQUE,
+	password      BLOB    NOT NULL,
+	is_chirpy_red INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE chirps (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	body      TEXT    NOT NULL,
+	author_id INTEGER NOT NULL
+);
+
+CREATE TABLE invalid_refresh_tokens (
+	token      TEXT PRIMARY KEY,
+	revoked_at DATETIME NOT NULL
+);
+
+INSERT INTO users (id, email, password, is_chirpy_red) VALUES
+	(1, 'Alice@Example.com', X'68617368', 0),
+	(2, 'bob@example.com', X'68617368', 1);
+
+INSERT INTO chirps (id, body, author_id) VALUES
+	(1, 'Learning #Go today', 1),
+	(2, 'No tags here', 2),
+	(5, '#GO and #sqlite, together', 1);
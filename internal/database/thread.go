@@ -0,0 +1,59 @@
+package database
+
+import (
+	"github.com/BrownieBrown/dolores/internal/models"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultThreadDepth = 10
+	MaxThreadDepth     = 50
+)
+
+// tombstone is what remains of a deleted chirp that still has replies.
+func tombstone(chirp models.Chirp) models.Chirp {
+	return models.Chirp{ID: chirp.ID, Kind: chirp.Kind, CreatedAt: chirp.CreatedAt, UpdatedAt: time.Now().UTC(), InReplyTo: chirp.InReplyTo, Deleted: true}
+}
+
+// buildThread returns the conversation id belongs to, starting at its root
+// and nested depth replies deep.
+func buildThread(tx Tx, id string, depth int) (models.ChirpThread, error) {
+	chirp, err := tx.GetChirp(id)
+	if err != nil {
+		return models.ChirpThread{}, err
+	}
+
+	for chirp.InReplyTo != 0 {
+		chirp, err = tx.GetChirp(strconv.Itoa(chirp.InReplyTo))
+		if err != nil {
+			return models.ChirpThread{}, err
+		}
+	}
+
+	return expandThread(tx, chirp, depth)
+}
+
+func expandThread(tx Tx, chirp models.Chirp, depth int) (models.ChirpThread, error) {
+	thread := models.ChirpThread{Chirp: chirp, Replies: []models.ChirpThread{}}
+
+	replies, err := tx.GetReplies(strconv.Itoa(chirp.ID))
+	if err != nil {
+		return models.ChirpThread{}, err
+	}
+
+	if depth <= 0 {
+		thread.MoreReplies = len(replies) > 0
+		return thread, nil
+	}
+
+	for _, reply := range replies {
+		replyThread, err := expandThread(tx, reply, depth-1)
+		if err != nil {
+			return models.ChirpThread{}, err
+		}
+		thread.Replies = append(thread.Replies, replyThread)
+	}
+
+	return thread, nil
+}
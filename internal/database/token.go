@@ -5,37 +5,28 @@ import (
 )
 
 func (db *DB) RefreshTokenIsInvalid(token string) bool {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	db.mux.RLock()
+	defer db.mux.RUnlock()
 
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return false
-	}
-
-	for refreshToken := range dbContent.InvalidRefreshTokens {
-		if refreshToken == token {
-			return true
-		}
-	}
-
-	return false
+	return db.refreshTokenIsInvalid(token)
 }
 
-func (db *DB) InvalidateRefreshToken(token string) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+func (tx *dbTx) RefreshTokenIsInvalid(token string) bool {
+	return tx.db.refreshTokenIsInvalid(token)
+}
 
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return err
-	}
+func (db *DB) refreshTokenIsInvalid(token string) bool {
+	_, ok := db.data.InvalidRefreshTokens[token]
 
-	if dbContent.InvalidRefreshTokens == nil {
-		dbContent.InvalidRefreshTokens = make(map[string]time.Time)
-	}
+	return ok
+}
 
-	dbContent.InvalidRefreshTokens[token] = time.Now()
+func (db *DB) InvalidateRefreshToken(token string) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.InvalidateRefreshToken(token)
+	})
+}
 
-	return db.writeDB(dbContent)
+func (tx *dbTx) InvalidateRefreshToken(token string) error {
+	return tx.put("invalid_refresh_tokens", token, time.Now().UTC())
 }
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// dbTx applies its writes to the resident data straight away, so later
+// reads in the same transaction see them, and remembers how to undo them.
+type dbTx struct {
+	db      *DB
+	entries []walEntry
+	undo    []walEntry
+}
+
+func (db *DB) Tx(fn func(tx Tx) error) (err error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	tx := &dbTx{db: db}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	if len(tx.entries) == 0 {
+		return nil
+	}
+
+	if err := db.persist(tx.entries); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	return nil
+}
+
+func (tx *dbTx) put(table, key string, value any) error {
+	return tx.write(putEntry(table, key, value))
+}
+
+func (tx *dbTx) delete(table, key string) error {
+	return tx.write(deleteEntry(table, key))
+}
+
+func (tx *dbTx) write(entry walEntry) error {
+	undo, err := tx.db.data.currentEntry(entry.Table, entry.Key)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.db.applyEntry(entry); err != nil {
+		return err
+	}
+
+	tx.entries = append(tx.entries, entry)
+	tx.undo = append(tx.undo, undo)
+
+	return nil
+}
+
+func (tx *dbTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		// Undo entries were captured from the data itself and always apply.
+		_ = tx.db.applyEntry(tx.undo[i])
+	}
+	tx.entries = nil
+	tx.undo = nil
+}
+
+// nextID allocates the next ID of table.
+func (tx *dbTx) nextID(table string) (int, error) {
+	id := tx.db.data.Sequences[table] + 1
+	if err := tx.put("sequences", table, id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// currentEntry returns the entry that would recreate the current state of
+// key in table: a put of its value, or a delete if it does not exist.
+func (dbContent *DBStructure) currentEntry(table, key string) (walEntry, error) {
+	var value any
+	var ok bool
+	switch table {
+	case "chirps", "users", "revisions", "notifications", "drafts":
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return walEntry{}, err
+		}
+		switch table {
+		case "chirps":
+			value, ok = lookup(dbContent.Chirps, id)
+		case "users":
+			value, ok = lookup(dbContent.Users, id)
+		case "revisions":
+			value, ok = lookup(dbContent.Revisions, id)
+		case "notifications":
+			value, ok = lookup(dbContent.Notifications, id)
+		case "drafts":
+			value, ok = lookup(dbContent.Drafts, id)
+		}
+	case "invalid_refresh_tokens":
+		value, ok = lookup(dbContent.InvalidRefreshTokens, key)
+	case "likes":
+		value, ok = lookup(dbContent.Likes, key)
+	case "follows":
+		value, ok = lookup(dbContent.Follows, key)
+	case "bookmarks":
+		value, ok = lookup(dbContent.Bookmarks, key)
+	case "sequences":
+		value, ok = lookup(dbContent.Sequences, key)
+	}
+
+	if !ok {
+		return deleteEntry(table, key), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return walEntry{}, err
+	}
+
+	return walEntry{Op: walPut, Table: table, Key: key, Value: data}, nil
+}
+
+func lookup[K comparable, V any](m map[K]V, key K) (any, bool) {
+	value, ok := m[key]
+	return value, ok
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestTxRollback fails a transaction that touched chirps, users and refresh
+// tokens. None of it may show, neither straight away nor after a restart.
+func TestTxRollback(t *testing.T) {
+	for _, bc := range []struct {
+		name string
+		open func(path string) (Store, error)
+	}{
+		{"json", func(path string) (Store, error) {
+			return NewDB(path, Durability{SyncEveryWrite: true})
+		}},
+		{"sqlite", func(path string) (Store, error) {
+			return NewSQLiteDB(path)
+		}},
+	} {
+		t.Run(bc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "database")
+			store, err := bc.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := store.CreateUser(models.SignUpRequest{Email: "alice@example.com", Handle: "alice", Password: "password"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept, err := store.CreateChirp(models.Chirp{Body: "kept", AuthorID: user.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			boom := errors.New("boom")
+			err = store.Tx(func(tx Tx) error {
+				if _, err := tx.CreateChirp(models.Chirp{Body: "rolled back", AuthorID: user.ID}); err != nil {
+					return err
+				}
+				if err := tx.DeleteChirp(strconv.Itoa(kept.ID)); err != nil {
+					return err
+				}
+				changed := user
+				changed.Email = "changed@example.com"
+				if err := tx.UpdateUser(changed); err != nil {
+					return err
+				}
+				if err := tx.InvalidateRefreshToken("token"); err != nil {
+					return err
+				}
+				if _, err := tx.GetChirp(strconv.Itoa(kept.ID)); err == nil {
+					t.Error("the transaction still sees the chirp it deleted")
+				}
+				return boom
+			})
+			if !errors.Is(err, boom) {
+				t.Fatalf("Tx returned %v, want the error of its function", err)
+			}
+
+			check := func(store Store) {
+				t.Helper()
+
+				page, err := store.ListChirps(ChirpQuery{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page.Chirps) != 1 || page.Chirps[0].ID != kept.ID {
+					t.Errorf("chirps are %v, want only the one created before", page.Chirps)
+				}
+				if _, err := store.GetUserByEmail("alice@example.com"); err != nil {
+					t.Errorf("user is not found by the old email: %v", err)
+				}
+				if _, err := store.GetUserByEmail("changed@example.com"); err == nil {
+					t.Error("user is found by the new email")
+				}
+				if store.RefreshTokenIsInvalid("token") {
+					t.Error("refresh token is still revoked")
+				}
+			}
+
+			check(store)
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = bc.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			check(store)
+		})
+	}
+}
+
+// TestTxLogFailure makes the log unwritable, so the write fails after the
+// transaction was applied in memory. It must not come back after a restart.
+func TestTxLogFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	db, err := NewDB(path, Durability{SyncEveryWrite: true, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(path+".wal", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateChirp(models.Chirp{Body: "lost", AuthorID: 1}); err == nil {
+		t.Fatal("CreateChirp succeeded without a log")
+	}
+	if _, err := db.GetChirp("1"); err == nil {
+		t.Error("the failed chirp is visible")
+	}
+
+	if err := os.Remove(path + ".wal"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = NewDB(path, Durability{SyncEveryWrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.GetChirp("1"); err == nil {
+		t.Error("the failed chirp is back after a restart")
+	}
+	chirp, err := db.CreateChirp(models.Chirp{Body: "next", AuthorID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.ID != 1 {
+		t.Errorf("next chirp got ID %d, want 1", chirp.ID)
+	}
+}
@@ -4,32 +4,51 @@ import (
 	"errors"
 	"github.com/BrownieBrown/dolores/internal/models"
 	"golang.org/x/crypto/bcrypt"
+	"regexp"
+	"strconv"
 )
 
 func (db *DB) emailExists(email string) bool {
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return false
-	}
+	_, ok := db.indexes.userByEmail[emailKey(email)]
 
-	for _, user := range dbContent.Users {
-		if user.Email == email {
-			return true
-		}
-	}
+	return ok
+}
+
+func (db *DB) handleExists(handle string) bool {
+	_, ok := db.indexes.userByHandle[handleKey(handle)]
 
-	return false
+	return ok
 }
 
-func (db *DB) validateSignUpRequest(signupReq models.SignUpRequest) error {
+var handlePattern = regexp.MustCompile(`^\w{1,15}$`)
+
+// ValidHandle reports whether handle can be @mentioned: 1 to 15 ASCII
+// letters, digits or underscores.
+func ValidHandle(handle string) bool {
+	return handlePattern.MatchString(handle)
+}
+
+func validateSignUpRequest(signupReq models.SignUpRequest, emailExists, handleExists func(string) bool) error {
 	if signupReq.Email == "" {
 		return errors.New("email required")
 	}
 
-	if db.emailExists(signupReq.Email) {
+	if emailExists(signupReq.Email) {
 		return errors.New("email already exists")
 	}
 
+	if signupReq.Handle == "" {
+		return errors.New("handle required")
+	}
+
+	if !ValidHandle(signupReq.Handle) {
+		return errors.New("invalid handle")
+	}
+
+	if handleExists(signupReq.Handle) {
+		return errors.New("handle already exists")
+	}
+
 	if signupReq.Password == "" {
 		return errors.New("password required")
 	}
@@ -38,15 +57,18 @@ func (db *DB) validateSignUpRequest(signupReq models.SignUpRequest) error {
 }
 
 func (db *DB) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	var newUser models.User
+	err := db.Tx(func(tx Tx) error {
+		var err error
+		newUser, err = tx.CreateUser(signupReq)
+		return err
+	})
 
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return models.User{}, err
-	}
+	return newUser, err
+}
 
-	err = db.validateSignUpRequest(signupReq)
+func (tx *dbTx) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
+	err := validateSignUpRequest(signupReq, tx.db.emailExists, tx.db.handleExists)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -57,11 +79,13 @@ func (db *DB) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
 
 	}
 
-	lastUserID := len(dbContent.Users)
-	newUser := models.User{ID: lastUserID + 1, Email: signupReq.Email, Password: hashedPassword, PremiumMember: false}
-	dbContent.Users[newUser.ID] = newUser
+	id, err := tx.nextID("users")
+	if err != nil {
+		return models.User{}, err
+	}
 
-	if err = db.writeDB(dbContent); err != nil {
+	newUser := models.User{ID: id, Email: signupReq.Email, Handle: signupReq.Handle, DisplayName: signupReq.DisplayName, Bio: signupReq.Bio, AvatarURL: signupReq.AvatarURL, Password: hashedPassword, PremiumMember: false}
+	if err = tx.put("users", strconv.Itoa(newUser.ID), newUser); err != nil {
 		return models.User{}, err
 	}
 
@@ -69,57 +93,157 @@ func (db *DB) CreateUser(signupReq models.SignUpRequest) (models.User, error) {
 }
 
 func (db *DB) UpdateUser(user models.User) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	return db.Tx(func(tx Tx) error {
+		return tx.UpdateUser(user)
+	})
+}
 
-	dbContent, err := db.loadDB()
-	if err != nil {
+func (tx *dbTx) UpdateUser(user models.User) error {
+	return tx.put("users", strconv.Itoa(user.ID), user)
+}
+
+func (db *DB) DeleteUser(id int) error {
+	return db.Tx(func(tx Tx) error {
+		return tx.DeleteUser(id)
+	})
+}
+
+// DeleteUser removes the user together with all of their chirps.
+func (tx *dbTx) DeleteUser(id int) error {
+	if _, ok := tx.db.data.Users[id]; !ok {
+		return errors.New("user not found")
+	}
+
+	if err := tx.deleteFollows(id); err != nil {
+		return err
+	}
+
+	if err := tx.deleteUserBookmarks(id); err != nil {
 		return err
 	}
 
-	dbContent.Users[user.ID] = user
+	likedIDs := make([]int, 0, len(tx.db.indexes.likesByUser[id]))
+	for chirpID := range tx.db.indexes.likesByUser[id] {
+		likedIDs = append(likedIDs, chirpID)
+	}
+
+	for _, chirpID := range likedIDs {
+		if _, err := tx.UnlikeChirp(strconv.Itoa(chirpID), id); err != nil {
+			return err
+		}
+	}
+
+	chirpIDs := make([]int, 0, len(tx.db.indexes.chirpsByAuthor[id]))
+	for chirpID := range tx.db.indexes.chirpsByAuthor[id] {
+		chirpIDs = append(chirpIDs, chirpID)
+	}
+
+	// Deleting one chirp can prune tombstones the user wrote further up the
+	// same thread, so skip whatever is already gone.
+	for _, chirpID := range chirpIDs {
+		if chirp, ok := tx.db.data.Chirps[chirpID]; !ok || chirp.Deleted {
+			continue
+		}
+		if err := tx.DeleteChirp(strconv.Itoa(chirpID)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.deleteNotifications(notificationIDs(tx.db.indexes.notifications, id)); err != nil {
+		return err
+	}
 
-	if err = db.writeDB(dbContent); err != nil {
+	if err := tx.deleteNotifications(notificationIDs(tx.db.indexes.notificationsByActor, id)); err != nil {
 		return err
 	}
 
-	return nil
+	for draftID := range tx.db.indexes.drafts[id] {
+		if err := tx.delete("drafts", strconv.Itoa(draftID)); err != nil {
+			return err
+		}
+	}
+
+	return tx.delete("users", strconv.Itoa(id))
 }
 
 func (db *DB) searchUserByEmail(email string) (models.User, error) {
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return models.User{}, err
+	id, ok := db.indexes.userByEmail[emailKey(email)]
+	if !ok {
+		return models.User{}, errors.New("user not found")
 	}
 
-	for _, user := range dbContent.Users {
-		if user.Email == email {
-			return user, nil
+	return db.data.Users[id], nil
+}
+
+func (db *DB) GetUserByEmail(email string) (models.User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.searchUserByEmail(email)
+}
+
+func (tx *dbTx) GetUserByEmail(email string) (models.User, error) {
+	return tx.db.searchUserByEmail(email)
+}
+
+func (db *DB) GetUserByID(id int) (models.User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getUserByID(id)
+}
+
+func (tx *dbTx) GetUserByID(id int) (models.User, error) {
+	return tx.db.getUserByID(id)
+}
+
+func (db *DB) GetUsersByID(ids []int) (map[int]models.User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.getUsersByID(ids), nil
+}
+
+func (tx *dbTx) GetUsersByID(ids []int) (map[int]models.User, error) {
+	return tx.db.getUsersByID(ids), nil
+}
+
+func (db *DB) getUsersByID(ids []int) map[int]models.User {
+	users := make(map[int]models.User, len(ids))
+	for _, id := range ids {
+		if user, ok := db.data.Users[id]; ok {
+			users[id] = user
 		}
 	}
 
-	return models.User{}, errors.New("user not found")
+	return users
 }
 
-func (db *DB) GetUserByEmail(email string) (models.User, error) {
-	user, err := db.searchUserByEmail(email)
-	if err != nil {
-		return models.User{}, err
+func (db *DB) getUserByID(id int) (models.User, error) {
+	user, ok := db.data.Users[id]
+	if !ok {
+		return models.User{}, errors.New("user not found")
 	}
 
 	return user, nil
 }
 
-func (db *DB) GetUserByID(id int) (models.User, error) {
-	dbContent, err := db.loadDB()
-	if err != nil {
-		return models.User{}, err
-	}
+func (db *DB) GetUserByHandle(handle string) (models.User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
 
-	user, ok := dbContent.Users[id]
+	return db.searchUserByHandle(handle)
+}
+
+func (tx *dbTx) GetUserByHandle(handle string) (models.User, error) {
+	return tx.db.searchUserByHandle(handle)
+}
+
+func (db *DB) searchUserByHandle(handle string) (models.User, error) {
+	id, ok := db.indexes.userByHandle[handleKey(handle)]
 	if !ok {
 		return models.User{}, errors.New("user not found")
 	}
 
-	return user, nil
+	return db.data.Users[id], nil
 }
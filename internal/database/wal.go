@@ -0,0 +1,173 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const (
+	walPut    = "put"
+	walDelete = "delete"
+)
+
+// walEntry is a single record of the append-only operation log. Entries are
+// idempotent so replaying one that already made it into the snapshot is safe.
+type walEntry struct {
+	Op    string          `json:"op"`
+	Table string          `json:"table"`
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func putEntry(table, key string, value any) walEntry {
+	// Values are plain model structs, which always marshal.
+	data, _ := json.Marshal(value)
+	return walEntry{Op: walPut, Table: table, Key: key, Value: data}
+}
+
+func deleteEntry(table, key string) walEntry {
+	return walEntry{Op: walDelete, Table: table, Key: key}
+}
+
+func (dbContent *DBStructure) apply(entry walEntry) error {
+	switch entry.Table {
+	case "chirps":
+		id, err := strconv.Atoi(entry.Key)
+		if err != nil {
+			return err
+		}
+		return applyMapEntry(dbContent.Chirps, id, entry)
+	case "users":
+		id, err := strconv.Atoi(entry.Key)
+		if err != nil {
+			return err
+		}
+		return applyMapEntry(dbContent.Users, id, entry)
+	case "revisions":
+		id, err := strconv.Atoi(entry.Key)
+		if err != nil {
+			return err
+		}
+		return applyMapEntry(dbContent.Revisions, id, entry)
+	case "notifications":
+		id, err := strconv.Atoi(entry.Key)
+		if err != nil {
+			return err
+		}
+		return applyMapEntry(dbContent.Notifications, id, entry)
+	case "drafts":
+		id, err := strconv.Atoi(entry.Key)
+		if err != nil {
+			return err
+		}
+		return applyMapEntry(dbContent.Drafts, id, entry)
+	case "invalid_refresh_tokens":
+		return applyMapEntry(dbContent.InvalidRefreshTokens, entry.Key, entry)
+	case "likes":
+		return applyMapEntry(dbContent.Likes, entry.Key, entry)
+	case "follows":
+		return applyMapEntry(dbContent.Follows, entry.Key, entry)
+	case "bookmarks":
+		return applyMapEntry(dbContent.Bookmarks, entry.Key, entry)
+	case "sequences":
+		return applyMapEntry(dbContent.Sequences, entry.Key, entry)
+	default:
+		return fmt.Errorf("wal: unknown table %q", entry.Table)
+	}
+}
+
+func applyMapEntry[K comparable, V any](m map[K]V, key K, entry walEntry) error {
+	switch entry.Op {
+	case walPut:
+		var value V
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			return err
+		}
+		m[key] = value
+	case walDelete:
+		delete(m, key)
+	default:
+		return fmt.Errorf("wal: unknown op %q", entry.Op)
+	}
+
+	return nil
+}
+
+// appendWAL writes entries as one record: a line holding the whole slice.
+// A record only counts once its newline is on disk, so a transaction is
+// replayed in full or not at all.
+func appendWAL(path string, entries []walEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	// A failed append is rolled back by the caller, so cut off whatever
+	// reached the file or a restart would replay it.
+	fail := func(err error) error {
+		_ = f.Truncate(info.Size())
+		f.Close()
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		return fail(err)
+	}
+
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+
+	return f.Close()
+}
+
+// readWAL returns the entries of every complete record in order.
+func readWAL(path string) ([]walEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A final record without its newline is a write that crashed before its
+	// fsync returned, so it was never acknowledged and is dropped whole.
+	if i := bytes.LastIndexByte(data, '\n'); i < len(data)-1 {
+		data = data[:i+1]
+	}
+
+	var entries []walEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record []walEntry
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("wal: corrupt record: %w", err)
+		}
+		entries = append(entries, record...)
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"bytes"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestWALTornTransaction cuts the log at every byte of its last transaction,
+// which deletes a user along with their chirps and likes. A restart must see
+// the transaction in full or not at all.
+func TestWALTornTransaction(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "database.json")
+	// Logged writes stay in the log until the next snapshot, an hour away.
+	db, err := NewDB(path, Durability{SyncEveryWrite: true, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	alice, err := db.CreateUser(models.SignUpRequest{Email: "alice@example.com", Handle: "alice", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := db.CreateUser(models.SignUpRequest{Email: "bob@example.com", Handle: "bob", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"first", "second"} {
+		chirp, err := db.CreateChirp(models.Chirp{Body: body, AuthorID: alice.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.LikeChirp(strconv.Itoa(chirp.ID), bob.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteUser(alice.ID); err != nil {
+		t.Fatal(err)
+	}
+	full, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(full, logged) {
+		t.Fatal("the log was rewritten instead of appended to")
+	}
+
+	reload := func(wal []byte) DBStructure {
+		t.Helper()
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "database.json")
+		if err := os.WriteFile(path, snapshot, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path+".wal", wal, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := NewDB(path, Durability{SyncEveryWrite: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		dbContent, err := db.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dbContent
+	}
+
+	before := reload(logged)
+	if len(before.Chirps) != 2 || len(before.Likes) != 2 {
+		t.Fatalf("before the delete: %d chirps and %d likes, want 2 of each", len(before.Chirps), len(before.Likes))
+	}
+	for cut := len(logged) + 1; cut < len(full); cut++ {
+		if got := reload(full[:cut]); !reflect.DeepEqual(got, before) {
+			t.Fatalf("log cut %d bytes into the delete replayed part of it", cut-len(logged))
+		}
+	}
+
+	after := reload(full)
+	if _, ok := after.Users[alice.ID]; ok {
+		t.Error("deleted user is back")
+	}
+	if len(after.Likes) != 0 {
+		t.Errorf("%d likes of deleted chirps are back", len(after.Likes))
+	}
+}
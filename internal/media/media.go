@@ -0,0 +1,251 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// URLPrefix is where the router serves the media directory.
+const URLPrefix = "/app/media/"
+
+const (
+	// maxPixels caps the size of a decoded image, so that a small file cannot
+	// expand into gigabytes of pixels.
+	maxPixels     = 24_000_000
+	thumbnailSize = 320
+	jpegQuality   = 90
+)
+
+var (
+	ErrTooLarge        = errors.New("media: file too large")
+	ErrUnsupportedType = errors.New("media: unsupported file type")
+	ErrInvalidImage    = errors.New("media: invalid image")
+	ErrNotFound        = errors.New("media: not found")
+)
+
+var idPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// format is an image type uploads may have. Every upload is decoded and
+// encoded again, which drops EXIF and any other metadata it carried.
+type format struct {
+	ext          string
+	decode       func(io.Reader) (image.Image, error)
+	decodeConfig func(io.Reader) (image.Config, error)
+	encode       func(io.Writer, image.Image) error
+}
+
+var formats = map[string]format{
+	"image/jpeg": {
+		ext:          ".jpg",
+		decode:       jpeg.Decode,
+		decodeConfig: jpeg.DecodeConfig,
+		encode: func(w io.Writer, img image.Image) error {
+			return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+		},
+	},
+	"image/png": {
+		ext:          ".png",
+		decode:       png.Decode,
+		decodeConfig: png.DecodeConfig,
+		encode:       png.Encode,
+	},
+}
+
+// Store keeps uploaded images on disk, content addressed: an image with ID
+// abcd… lives at dir/ab/abcd….jpg, next to its thumbnail and a JSON file
+// describing it.
+type Store struct {
+	dir      string
+	maxBytes int64
+}
+
+// FileSystem serves the files under dir but none of its directories, so
+// uploads can only be fetched by their URLs and never listed. Unpublished
+// drafts may hold some of them.
+func FileSystem(dir string) http.FileSystem {
+	return filesOnly{http.Dir(dir)}
+}
+
+type filesOnly struct {
+	http.FileSystem
+}
+
+func (fo filesOnly) Open(name string) (http.File, error) {
+	f, err := fo.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if info.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+
+	return f, nil
+}
+
+func NewStore(dir string, maxBytes int64) *Store {
+	return &Store{dir: dir, maxBytes: maxBytes}
+}
+
+// Save validates and cleans the image read from r, stores it with a
+// thumbnail and returns its attachment. Saving an image that is already
+// stored returns the existing one.
+func (s *Store) Save(r io.Reader) (models.Attachment, error) {
+	data, err := io.ReadAll(io.LimitReader(r, s.maxBytes+1))
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	if int64(len(data)) > s.maxBytes {
+		return models.Attachment{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	f, ok := formats[contentType]
+	if !ok {
+		return models.Attachment{}, ErrUnsupportedType
+	}
+
+	config, err := f.decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return models.Attachment{}, ErrInvalidImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return models.Attachment{}, ErrTooLarge
+	}
+
+	img, err := f.decode(bytes.NewReader(data))
+	if err != nil {
+		return models.Attachment{}, ErrInvalidImage
+	}
+
+	// The orientation is part of the metadata being dropped, so it is
+	// applied to the pixels instead.
+	if contentType == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+
+	var encoded bytes.Buffer
+	if err := f.encode(&encoded, img); err != nil {
+		return models.Attachment{}, err
+	}
+
+	var thumb bytes.Buffer
+	if err := f.encode(&thumb, thumbnail(img)); err != nil {
+		return models.Attachment{}, err
+	}
+
+	sum := sha256.Sum256(encoded.Bytes())
+	id := hex.EncodeToString(sum[:])
+	bounds := img.Bounds()
+	attachment := s.attachment(id, contentType, bounds.Dx(), bounds.Dy(), int64(encoded.Len()))
+
+	meta, err := json.Marshal(attachment)
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	// The description goes last, so Get only finds images whose files are
+	// all in place.
+	if err := writeFile(s.path(id, f.ext), encoded.Bytes()); err != nil {
+		return models.Attachment{}, err
+	}
+	if err := writeFile(s.path(id, "_thumb"+f.ext), thumb.Bytes()); err != nil {
+		return models.Attachment{}, err
+	}
+	if err := writeFile(s.path(id, ".json"), meta); err != nil {
+		return models.Attachment{}, err
+	}
+
+	return attachment, nil
+}
+
+// Get returns the attachment for a stored image.
+func (s *Store) Get(id string) (models.Attachment, error) {
+	if !idPattern.MatchString(id) {
+		return models.Attachment{}, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id, ".json"))
+	if os.IsNotExist(err) {
+		return models.Attachment{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Attachment{}, err
+	}
+
+	var attachment models.Attachment
+	if err := json.Unmarshal(data, &attachment); err != nil {
+		return models.Attachment{}, err
+	}
+
+	return s.attachment(id, attachment.ContentType, attachment.Width, attachment.Height, attachment.Size), nil
+}
+
+func (s *Store) attachment(id, contentType string, width, height int, size int64) models.Attachment {
+	ext := formats[contentType].ext
+	return models.Attachment{
+		ID:           id,
+		ContentType:  contentType,
+		Width:        width,
+		Height:       height,
+		Size:         size,
+		URL:          URLPrefix + id[:2] + "/" + id + ext,
+		ThumbnailURL: URLPrefix + id[:2] + "/" + id + "_thumb" + ext,
+	}
+}
+
+func (s *Store) path(id, suffix string) string {
+	return filepath.Join(s.dir, id[:2], id+suffix)
+}
+
+// writeFile writes data to path through a temporary file, so readers never
+// see a partial file. Files already there are left alone: their name is
+// their content.
+func writeFile(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,7 +1,107 @@
 package models
 
+import "time"
+
+// Chirp kinds. A rechirp reposts OriginalID as is and has no body of its
+// own; a quote adds a body to it.
+const (
+	ChirpKindChirp   = "chirp"
+	ChirpKindRechirp = "rechirp"
+	ChirpKindQuote   = "quote"
+)
+
 type Chirp struct {
-	ID       int    `json:"id"`
-	Body     string `json:"body"`
-	AuthorID int    `json:"author_id"`
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+	Body string `json:"body"`
+	// Tags are the hashtags in Body, case folded.
+	Tags []string `json:"tags,omitempty"`
+	// Media are the images attached to the chirp, in the order given.
+	Media    []Attachment `json:"media,omitempty"`
+	AuthorID int          `json:"author_id"`
+	// AuthorHandle is filled in per request and never stored.
+	AuthorHandle string    `json:"author_handle,omitempty"`
+	OriginalID   int       `json:"original_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Edited       bool      `json:"edited"`
+	InReplyTo    int       `json:"in_reply_to,omitempty"`
+	LikeCount    int       `json:"like_count"`
+	// PinnedAt is set while the author has the chirp pinned to the top of
+	// their feed.
+	PinnedAt *time.Time `json:"pinned_at,omitempty"`
+	// LikedByMe is filled in per request for the authenticated user and is
+	// never stored.
+	LikedByMe bool `json:"liked_by_me"`
+	// Original is the chirp OriginalID refers to, embedded per request and
+	// never stored. It is a tombstone if the original has been deleted.
+	Original *Chirp `json:"original,omitempty"`
+	// Deleted marks a tombstone: a deleted chirp kept, without its body or
+	// author, because other chirps still reply to it.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// ChirpThread is a chirp with its replies, nested down to a depth limit.
+type ChirpThread struct {
+	Chirp
+	Replies []ChirpThread `json:"replies"`
+	// MoreReplies is set when the depth limit cut off this chirp's replies.
+	MoreReplies bool `json:"more_replies,omitempty"`
+}
+
+// ChirpRevision is one version of a chirp's body, numbered from 1 for the
+// original. CreatedAt is when that version was written.
+type ChirpRevision struct {
+	Revision  int       `json:"revision"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Like struct {
+	ChirpID   int       `json:"chirp_id"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Bookmark is a chirp a user saved for later. Bookmarks are private to the
+// user.
+type Bookmark struct {
+	ChirpID   int       `json:"chirp_id"`
+	UserID    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TrendingTag is a hashtag with its decayed score over the trending window
+// and the number of chirps using it there.
+type TrendingTag struct {
+	Tag   string  `json:"tag"`
+	Score float64 `json:"score"`
+	Count int     `json:"count"`
+}
+
+// Attachment is an image uploaded to the media store. ID is the SHA-256 of
+// the stored file, so the same image is only kept once.
+type Attachment struct {
+	ID           string `json:"id"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// Draft is a chirp its author has not published yet. A draft with a
+// PublishAt is scheduled and is published at that time; publishing turns it
+// into a new chirp and removes the draft.
+type Draft struct {
+	ID         int          `json:"id"`
+	Body       string       `json:"body"`
+	AuthorID   int          `json:"author_id"`
+	InReplyTo  int          `json:"in_reply_to,omitempty"`
+	OriginalID int          `json:"original_id,omitempty"`
+	Media      []Attachment `json:"media,omitempty"`
+	PublishAt  *time.Time   `json:"publish_at,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
 }
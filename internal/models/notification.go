@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Notification kinds.
+const (
+	NotificationMention = "mention"
+	NotificationReply   = "reply"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+)
+
+// Notification tells UserID that ActorID mentioned them, replied to them,
+// liked one of their chirps or followed them. ChirpID is the mentioning
+// chirp, the reply or the liked chirp, and is 0 for follows.
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Kind      string    `json:"kind"`
+	ActorID   int       `json:"actor_id"`
+	ChirpID   int       `json:"chirp_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Read      bool      `json:"read"`
+}
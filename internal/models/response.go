@@ -1,8 +1,11 @@
 package models
 
+import "time"
+
 type SignInResponse struct {
 	ID            int    `json:"id"`
 	Email         string `json:"email"`
+	Handle        string `json:"handle,omitempty"`
 	PremiumMember bool   `json:"is_chirpy_red"`
 	Token         string `json:"token"`
 	RefreshToken  string `json:"refresh_token"`
@@ -14,23 +17,41 @@ type SignInRequest struct {
 }
 
 type SignUpRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string `json:"email"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Password    string `json:"password"`
 }
 
 type SignUpResponse struct {
 	Email         string `json:"email"`
+	Handle        string `json:"handle,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	Bio           string `json:"bio,omitempty"`
+	AvatarURL     string `json:"avatar_url,omitempty"`
 	ID            int    `json:"id"`
 	PremiumMember bool   `json:"is_chirpy_red"`
 }
 
+// UpdateUserRequest leaves the handle and the profile fields that are
+// omitted unchanged.
 type UpdateUserRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string  `json:"email"`
+	Handle      string  `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Password    string  `json:"password"`
 }
 
 type UpdateUserResponse struct {
 	Email         string `json:"email"`
+	Handle        string `json:"handle,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	Bio           string `json:"bio,omitempty"`
+	AvatarURL     string `json:"avatar_url,omitempty"`
 	ID            int    `json:"id"`
 	PremiumMember bool   `json:"is_chirpy_red"`
 }
@@ -38,3 +59,33 @@ type UpdateUserResponse struct {
 type RefreshTokenResponse struct {
 	AccessToken string `json:"token"`
 }
+
+type ChirpPageResponse struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+}
+
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+}
+
+type CreateChirpRequest struct {
+	Body       string `json:"body"`
+	InReplyTo  int    `json:"in_reply_to"`
+	OriginalID int    `json:"original_id"`
+	// MediaIDs are attachments uploaded to /api/media beforehand.
+	MediaIDs []string `json:"media_ids"`
+	// Draft saves the chirp as a draft instead of posting it, and PublishAt
+	// schedules it to be posted later.
+	Draft     bool       `json:"draft"`
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// UpdateDraftRequest replaces a draft's body and schedule; a null
+// publish_at keeps it as an unscheduled draft.
+type UpdateDraftRequest struct {
+	Body      string     `json:"body"`
+	PublishAt *time.Time `json:"publish_at"`
+}
@@ -1,8 +1,34 @@
 package models
 
+import "time"
+
 type User struct {
-	ID            int    `json:"id"`
-	Email         string `json:"email"`
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	// Handle is the optional name other users @mention the user by. It is
+	// unique, ignoring case.
+	Handle        string `json:"handle,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	Bio           string `json:"bio,omitempty"`
+	AvatarURL     string `json:"avatar_url,omitempty"`
 	Password      []byte `json:"password"`
 	PremiumMember bool   `json:"is_chirpy_red"`
 }
+
+// UserProfile is what anyone may see of a user.
+type UserProfile struct {
+	ID             int    `json:"id"`
+	Handle         string `json:"handle"`
+	DisplayName    string `json:"display_name"`
+	Bio            string `json:"bio"`
+	AvatarURL      string `json:"avatar_url"`
+	PremiumMember  bool   `json:"is_chirpy_red"`
+	FollowerCount  int    `json:"follower_count"`
+	FollowingCount int    `json:"following_count"`
+}
+
+type Follow struct {
+	FollowerID int       `json:"follower_id"`
+	FolloweeID int       `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
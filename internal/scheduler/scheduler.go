@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"log"
+	"time"
+)
+
+// Clock is where the scheduler gets the time from, so tests can drive it.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+// Scheduler publishes scheduled drafts once their time has come. It keeps no
+// state of its own: every pass reads the due drafts from the store, so
+// nothing is lost across restarts.
+type Scheduler struct {
+	store    database.Store
+	clock    Clock
+	interval time.Duration
+}
+
+// New checks store for due drafts every interval, by clock.
+func New(store database.Store, clock Clock, interval time.Duration) *Scheduler {
+	return &Scheduler{store: store, clock: clock, interval: interval}
+}
+
+// Run publishes due drafts until ctx is done. The first pass runs straight
+// away, which catches up on drafts that fell due while the server was down.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		if _, err := s.PublishDue(); err != nil {
+			log.Printf("scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// PublishDue publishes every draft scheduled for now or earlier and returns
+// the chirps it posted. A draft that fails is left for the next pass and
+// does not hold up the others.
+func (s *Scheduler) PublishDue() ([]models.Chirp, error) {
+	now := s.clock.Now()
+	drafts, err := s.store.GetDueDrafts(now)
+	if err != nil {
+		return nil, err
+	}
+
+	var published []models.Chirp
+	var errs []error
+	for _, draft := range drafts {
+		var chirp models.Chirp
+		err := s.store.Tx(func(tx database.Tx) error {
+			// The author may have rescheduled or cancelled it since.
+			current, err := tx.GetDraft(draft.ID)
+			if errors.Is(err, database.ErrDraftNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if current.PublishAt == nil || current.PublishAt.After(now) {
+				return nil
+			}
+
+			chirp, err = database.PublishDraft(tx, draft.ID)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if chirp.ID != 0 {
+			published = append(published, chirp)
+		}
+	}
+
+	return published, errors.Join(errs...)
+}
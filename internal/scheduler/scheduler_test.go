@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"context"
+	"github.com/BrownieBrown/dolores/internal/database"
+	"github.com/BrownieBrown/dolores/internal/models"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when set. Every After call is reported on waiting,
+// which tells a test that Run has finished a pass.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiting chan time.Duration
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, waiting: make(chan time.Duration, 1)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// After never fires; Run only gets past it when its context is done.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waiting <- d
+	return make(chan time.Time)
+}
+
+// staleStore hands out the due drafts as they were when it was made, as if
+// they changed between the scheduler listing them and publishing them.
+type staleStore struct {
+	database.Store
+	due []models.Draft
+}
+
+func (s staleStore) GetDueDrafts(now time.Time) ([]models.Draft, error) {
+	return s.due, nil
+}
+
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func at(d time.Duration) *time.Time {
+	t := start.Add(d)
+	return &t
+}
+
+func createDraft(t *testing.T, store database.Store, body string, publishAt *time.Time) models.Draft {
+	t.Helper()
+
+	draft, err := store.CreateDraft(models.Draft{Body: body, AuthorID: 1, PublishAt: publishAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return draft
+}
+
+func publishDue(t *testing.T, s *Scheduler) []string {
+	t.Helper()
+
+	chirps, err := s.PublishDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := make([]string, 0, len(chirps))
+	for _, chirp := range chirps {
+		bodies = append(bodies, chirp.Body)
+	}
+
+	return bodies
+}
+
+func assertDraftGone(t *testing.T, store database.Store, id int) {
+	t.Helper()
+
+	if _, err := store.GetDraft(id); err == nil {
+		t.Errorf("draft %d is still there after being published", id)
+	}
+}
+
+func TestPublishDueDraft(t *testing.T) {
+	store := database.NewMemoryDB()
+	clock := newFakeClock(start)
+	s := New(store, clock, time.Minute)
+
+	due := createDraft(t, store, "due", at(time.Minute))
+	createDraft(t, store, "later", at(time.Hour))
+	createDraft(t, store, "unscheduled", nil)
+
+	if got := publishDue(t, s); len(got) != 0 {
+		t.Fatalf("published %v before anything was due", got)
+	}
+
+	clock.Set(start.Add(time.Minute))
+	if got := publishDue(t, s); len(got) != 1 || got[0] != "due" {
+		t.Fatalf("published %v, want [due]", got)
+	}
+	assertDraftGone(t, store, due.ID)
+
+	if got := publishDue(t, s); len(got) != 0 {
+		t.Fatalf("published %v again", got)
+	}
+
+	drafts, err := store.GetDrafts(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drafts) != 2 {
+		t.Errorf("%d drafts left, want the later and unscheduled ones", len(drafts))
+	}
+}
+
+func TestRescheduledDraftIsNotPublishedAtOldTime(t *testing.T) {
+	store := database.NewMemoryDB()
+	clock := newFakeClock(start.Add(time.Minute))
+
+	draft := createDraft(t, store, "moved", at(time.Minute))
+	due, err := store.GetDueDrafts(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	draft.PublishAt = at(time.Hour)
+	if _, err := store.UpdateDraft(draft); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(staleStore{Store: store, due: due}, clock, time.Minute)
+	if got := publishDue(t, s); len(got) != 0 {
+		t.Fatalf("published %v at the old time", got)
+	}
+	if _, err := store.GetDraft(draft.ID); err != nil {
+		t.Fatalf("rescheduled draft is gone: %v", err)
+	}
+
+	clock.Set(start.Add(time.Hour))
+	s = New(store, clock, time.Minute)
+	if got := publishDue(t, s); len(got) != 1 || got[0] != "moved" {
+		t.Fatalf("published %v at the new time, want [moved]", got)
+	}
+}
+
+func TestDeletedDraftIsNotPublished(t *testing.T) {
+	store := database.NewMemoryDB()
+	clock := newFakeClock(start.Add(time.Minute))
+
+	draft := createDraft(t, store, "cancelled", at(time.Minute))
+	due, err := store.GetDueDrafts(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeleteDraft(draft.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(staleStore{Store: store, due: due}, clock, time.Minute)
+	if got := publishDue(t, s); len(got) != 0 {
+		t.Fatalf("published deleted draft: %v", got)
+	}
+
+	page, err := store.ListChirps(database.ChirpQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Chirps) != 0 {
+		t.Errorf("%d chirps posted, want none", len(page.Chirps))
+	}
+}
+
+// TestRunCatchesUpAfterRestart schedules drafts, stops the server and starts
+// it again after they fell due. The first pass of Run must publish them.
+func TestRunCatchesUpAfterRestart(t *testing.T) {
+	for _, bc := range []struct {
+		name string
+		open func(path string) (database.Store, error)
+	}{
+		{"json", func(path string) (database.Store, error) {
+			return database.NewDB(path, database.Durability{SyncEveryWrite: true})
+		}},
+		{"sqlite", func(path string) (database.Store, error) {
+			return database.NewSQLiteDB(path)
+		}},
+	} {
+		t.Run(bc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "database")
+
+			store, err := bc.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := createDraft(t, store, "first", at(time.Minute))
+			second := createDraft(t, store, "second", at(2*time.Minute))
+			createDraft(t, store, "tomorrow", at(24*time.Hour))
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			store, err = bc.open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			clock := newFakeClock(start.Add(time.Hour))
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				New(store, clock, time.Minute).Run(ctx)
+			}()
+
+			select {
+			case d := <-clock.waiting:
+				if d != time.Minute {
+					t.Errorf("waiting %v between passes, want 1m", d)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not finish its first pass")
+			}
+			cancel()
+			<-done
+
+			page, err := store.ListChirps(database.ChirpQuery{SortOrder: "asc"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var bodies []string
+			for _, chirp := range page.Chirps {
+				bodies = append(bodies, chirp.Body)
+			}
+			if len(bodies) != 2 || bodies[0] != "first" || bodies[1] != "second" {
+				t.Errorf("published %v, want [first second]", bodies)
+			}
+			assertDraftGone(t, store, first.ID)
+			assertDraftGone(t, store, second.ID)
+		})
+	}
+}